@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// speedOfSoundNMPerSec is the speed of sound in the standard atmosphere at sea level
+const speedOfSoundNMPerSec = 340.29 / 1852
+
+// acousticEstimate is where a plane was when it made the sound the observer hears now
+type acousticEstimate struct {
+	plane      plane
+	delay      time.Duration
+	lat        float64
+	lon        float64
+	distance   float64
+	bearing    float64
+	slantRange float64
+	level      float64
+}
+
+// positionAt estimates where p was at t, interpolating along its trail where possible and
+// dead reckoning from its track and ground speed otherwise
+func (m *model) positionAt(p plane, t time.Time, now time.Time) (float64, float64) {
+	trail := m.trails[p.Hex]
+	for i := 1; i < len(trail); i++ {
+		a, b := trail[i-1], trail[i]
+		if t.Before(a.Time) || t.After(b.Time) {
+			continue
+		}
+		span := b.Time.Sub(a.Time).Seconds()
+		if span <= 0 {
+			return b.Lat, b.Lon
+		}
+		f := t.Sub(a.Time).Seconds() / span
+		return a.Lat + (b.Lat-a.Lat)*f, a.Lon + (b.Lon-a.Lon)*f
+	}
+
+	if p.GroundSpeed <= 0 {
+		return p.Lat, p.Lon
+	}
+	dt := t.Sub(p.positionTime(now)).Hours()
+	track := p.Track * math.Pi / 180
+	if dt < 0 {
+		track += math.Pi
+		dt = -dt
+	}
+	return destinationPoint(p.Lat, p.Lon, track, p.GroundSpeed*dt)
+}
+
+// emissionPosition back-computes where p was when the sound reaching the observer at now
+// was emitted, by iterating on the sound travel time over the slant range
+func (m *model) emissionPosition(p plane, now time.Time) (acousticEstimate, bool) {
+	if !p.HasAltitude {
+		return acousticEstimate{}, false
+	}
+
+	e := acousticEstimate{plane: p}
+	delay := p.SlantRange / speedOfSoundNMPerSec
+	for range 5 {
+		e.delay = time.Duration(delay * float64(time.Second))
+		e.lat, e.lon = m.positionAt(p, now.Add(-e.delay), now)
+
+		e.distance, e.bearing = distanceAndBearing(m.lat, m.lon, e.lat, e.lon)
+		emitted := plane{DistanceFromObserver: e.distance, AltBaro: p.AltBaro, AltGeom: p.AltGeom}
+		setSkyPosition(&emitted, m.observerElevation)
+		e.slantRange = emitted.SlantRange
+
+		delay = e.slantRange / speedOfSoundNMPerSec
+	}
+	return e, true
+}
+
+// likelyAudible returns the aircraft most likely to be the one heard right now: the one
+// whose sound is estimated loudest from where it was emitted
+func (m *model) likelyAudible(now time.Time) (acousticEstimate, bool) {
+	var best acousticEstimate
+	found := false
+	for _, p := range m.planes {
+		e, ok := m.emissionPosition(p, now)
+		if !ok {
+			continue
+		}
+		level, audible := estimateNoise(p, e.slantRange)
+		if !audible {
+			continue
+		}
+		e.level = level
+		if !found || e.level > best.level {
+			best = e
+			found = true
+		}
+	}
+	return best, found
+}
+
+func (e acousticEstimate) describe() string {
+	name := strings.TrimSpace(e.plane.FlightCode)
+	if name == "" {
+		name = e.plane.Hex
+	}
+	return fmt.Sprintf("hearing: %s ~%.0f dB(A) (sound from %s ago, %.1f NM %s)",
+		name, e.level, formatCountdown(e.delay), e.slantRange, compassPoint(e.bearing))
+}
+
+// identifyHeard selects the likely audible aircraft in the table and reports it
+func (m *model) identifyHeard() {
+	m.heard, m.heardOK = m.likelyAudible(time.Now())
+	e, ok := m.heard, m.heardOK
+	if !ok {
+		m.statusMessage = "hearing: nothing within earshot"
+		return
+	}
+	for i, row := range m.tbl.Rows() {
+		if row[0] == e.plane.FlightCode {
+			m.tbl.SetCursor(i)
+			break
+		}
+	}
+	m.statusMessage = e.describe()
+}
+
+// renderHeardPosition marks where the likely audible aircraft was when it made the sound
+func (m *model) renderHeardPosition(ctx radarContext) {
+	e := m.heard
+	if !m.heardOK || e.distance > float64(m.radarRange) {
+		return
+	}
+	virtualDistance := e.distance * m.radarScale(ctx)
+	displayBearing := e.bearing - m.northOffset
+	posX := ctx.cx + int(virtualDistance*math.Sin(displayBearing))
+	posY := ctx.cy - int(virtualDistance*math.Cos(displayBearing)*m.aspectRatio)
+	if inBounds(ctx.width, ctx.height, posX, posY) {
+		c := &ctx.cells[posY][posX]
+		c.kind = "heard"
+		c.char = '@'
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -50,19 +51,50 @@ type plane struct {
 	Lat                  float64 `json:"lat"`
 	Lon                  float64 `json:"lon"`
 	Heading              float64 `json:"true_heading"`
+	Track                float64 `json:"track"`
+	GroundSpeed          float64 `json:"gs"`
+	SeenPos              float64 `json:"seen_pos"`
+	PolledAt             time.Time
+	AircraftType         string       `json:"t"`
+	Category             string       `json:"category"`
+	BaroRate             float64      `json:"baro_rate"`
+	AltBaro              altitudeFeet `json:"alt_baro"`
+	AltGeom              altitudeFeet `json:"alt_geom"`
 	BearingFromObserver  float64
 	DistanceFromObserver float64
+	ElevationAngle       float64
+	SlantRange           float64
+	HasAltitude          bool
+	NoiseLevel           float64
+	Audible              bool
+	InSector             bool
+	CPADistance          float64
+	CPATime              time.Time
+	Approaching          bool
 	RouteInfo            FlightRoute
 }
 
+// positionTime returns when the position of p was reported, or fallback if it wasn't polled
+func (p plane) positionTime(fallback time.Time) time.Time {
+	if p.PolledAt.IsZero() {
+		return fallback
+	}
+	return p.PolledAt.Add(-time.Duration(p.SeenPos * float64(time.Second)))
+}
+
 type cachedFlightRoute struct {
 	route    FlightRoute
 	cachedAt time.Time
 }
 
-var routeInfoCache = make(map[string]cachedFlightRoute)
+var (
+	routeInfoCache   = make(map[string]cachedFlightRoute)
+	routeInfoCacheMu sync.Mutex
+)
 
-const routeInfoCacheTTL = 10 * time.Minute
+// upstreamClient is used for every adsb.lol and adsbdb request, so a slow upstream can
+// only hold up a poll for so long
+var upstreamClient = &http.Client{Timeout: 10 * time.Second}
 
 func createEmptyFlightRoute() FlightRoute {
 	return FlightRoute{
@@ -77,22 +109,24 @@ func createEmptyFlightRoute() FlightRoute {
 }
 
 func SetFlightRouteInfo(p *plane) {
-	if _, ok := routeInfoCache[p.FlightCode]; ok {
-		if time.Since(routeInfoCache[p.FlightCode].cachedAt) <= routeInfoCacheTTL {
-			p.RouteInfo = routeInfoCache[p.FlightCode].route
-			return
-		}
+	routeInfoCacheMu.Lock()
+	cached, ok := routeInfoCache[p.FlightCode]
+	routeInfoCacheMu.Unlock()
+	if ok && time.Since(cached.cachedAt) <= settings().Sources.RouteCacheTTL {
+		p.RouteInfo = cached.route
+		return
 	}
 
-	url := fmt.Sprintf("https://api.adsbdb.com/v0/callsign/%s", strings.TrimSpace(p.FlightCode))
+	url := fmt.Sprintf("%s/%s", strings.TrimSuffix(settings().Sources.RouteURL, "/"), strings.TrimSpace(p.FlightCode))
 
 	var flightRouteInfo flightRouteResponse
 
-	res, err := http.Get(url)
+	res, err := upstreamClient.Get(url)
 	if err != nil {
 		p.RouteInfo = createEmptyFlightRoute()
 		return
 	}
+	defer res.Body.Close()
 
 	bodyBytes, err := io.ReadAll(res.Body)
 	if err != nil {
@@ -105,10 +139,7 @@ func SetFlightRouteInfo(p *plane) {
 	// Handle unknown callsign response
 	if strings.Contains(string(bodyBytes), "\"response\":\"unknown callsign\"") {
 		p.RouteInfo = createEmptyFlightRoute()
-		routeInfoCache[p.FlightCode] = cachedFlightRoute{
-			route:    p.RouteInfo,
-			cachedAt: time.Now(),
-		}
+		cacheFlightRoute(p.FlightCode, p.RouteInfo)
 		return
 	}
 
@@ -130,37 +161,52 @@ func SetFlightRouteInfo(p *plane) {
 	}
 
 	p.RouteInfo = flightRoute
-	routeInfoCache[p.FlightCode] = cachedFlightRoute{
-		route:    flightRoute,
+	cacheFlightRoute(p.FlightCode, flightRoute)
+}
+
+func cacheFlightRoute(flightCode string, route FlightRoute) {
+	routeInfoCacheMu.Lock()
+	defer routeInfoCacheMu.Unlock()
+	routeInfoCache[flightCode] = cachedFlightRoute{
+		route:    route,
 		cachedAt: time.Now(),
 	}
 }
 
-func GetLocalFlights(lat float64, lon float64, radius float64) []plane {
-	url := fmt.Sprintf("https://api.adsb.lol/v2/point/%.4f/%.4f/%f", lat, lon, radius)
+// GetLocalFlights queries adsb.lol for the aircraft within radius NM of a location and
+// looks up their routes
+func GetLocalFlights(lat float64, lon float64, radius float64) ([]plane, error) {
+	url := fmt.Sprintf("%s/%.4f/%.4f/%f", strings.TrimSuffix(settings().Sources.ADSBURL, "/"), lat, lon, radius)
 
 	var adsbResponse adsbResponse
-	res, err := http.Get(url)
+	res, err := upstreamClient.Get(url)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("aircraft API returned %s", res.Status)
+	}
 
 	bodyBytes, err := io.ReadAll(res.Body)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	log.Printf("API response: %s", string(bodyBytes))
 
 	if err := json.Unmarshal(bodyBytes, &adsbResponse); err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("decoding aircraft API response: %w", err)
 	}
 
 	log.Printf("adsbResponse: %+v", adsbResponse.Planes)
 
 	for i := range adsbResponse.Planes {
-		SetFlightRouteInfo(&adsbResponse.Planes[i])
+		p := &adsbResponse.Planes[i]
+		SetFlightRouteInfo(p)
+		if p.RouteInfo.Airline == "" {
+			p.RouteInfo.Airline = reference.airline(p.FlightCode)
+		}
 	}
 
-	return adsbResponse.Planes
+	return adsbResponse.Planes, nil
 }
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// Auth modes. In open mode everyone gets full access, in guest mode everyone can connect
+// but only members are unrestricted, and in members mode only members can connect.
+const (
+	authOpen    = "open"
+	authGuest   = "guest"
+	authMembers = "members"
+)
+
+// authConfig decides who can connect. Members are listed in an authorized_keys file or in
+// a directory of GitHub style <user>.keys files. Key files are read on every login so they
+// can be edited while the server is running.
+type authConfig struct {
+	Mode               string `toml:"mode" yaml:"mode"`
+	AuthorizedKeys     string `toml:"authorized_keys" yaml:"authorized_keys"`
+	UserKeysDir        string `toml:"user_keys_dir" yaml:"user_keys_dir"`
+	DeniedKeys         string `toml:"denied_keys" yaml:"denied_keys"`
+	GuestMaxRange      int    `toml:"guest_max_range" yaml:"guest_max_range"`
+	GuestFixedLocation bool   `toml:"guest_fixed_location" yaml:"guest_fixed_location"`
+}
+
+func (a *authConfig) validate(maxRange int) error {
+	switch a.Mode {
+	case authOpen:
+	case authGuest, authMembers:
+		if a.AuthorizedKeys == "" && a.UserKeysDir == "" {
+			return fmt.Errorf("auth mode %q needs --authorized-keys or --user-keys-dir to list members", a.Mode)
+		}
+	default:
+		return fmt.Errorf("unknown auth mode %q, expected open, guest or members", a.Mode)
+	}
+	if a.GuestMaxRange != 0 && (a.GuestMaxRange < MIN_RADAR_RANGE || a.GuestMaxRange > maxRange) {
+		return fmt.Errorf("guest max range must be between %d and %d NM", MIN_RADAR_RANGE, maxRange)
+	}
+	return nil
+}
+
+// keyInFile reports whether key is listed in an authorized_keys format file. A missing
+// file lists no keys.
+func keyInFile(path string, key ssh.PublicKey) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Could not read keys from %s: %v", path, err)
+		}
+		return false
+	}
+
+	for len(bytes.TrimSpace(data)) > 0 {
+		listed, _, _, rest, err := gossh.ParseAuthorizedKey(data)
+		if err != nil {
+			// ParseAuthorizedKey skips bad lines itself, so this is the end of the file
+			return false
+		}
+		if ssh.KeysEqual(listed, key) {
+			return true
+		}
+		data = rest
+	}
+	return false
+}
+
+func (a *authConfig) isDenied(key ssh.PublicKey) bool {
+	return a.DeniedKeys != "" && key != nil && keyInFile(a.DeniedKeys, key)
+}
+
+// isMember reports whether key is in the authorized_keys file or in the .keys file named
+// after the user they logged in as
+func (a *authConfig) isMember(user string, key ssh.PublicKey) bool {
+	if key == nil {
+		return false
+	}
+	if a.AuthorizedKeys != "" && keyInFile(a.AuthorizedKeys, key) {
+		return true
+	}
+	if a.UserKeysDir != "" && user != "" && !strings.ContainsAny(user, `/\`) && !strings.HasPrefix(user, ".") {
+		return keyInFile(filepath.Join(a.UserKeysDir, user+".keys"), key)
+	}
+	return false
+}
+
+// deniedKeyContextKey marks a connection that offered a denied key, so it can't fall back to
+// keyboard-interactive and get in as a guest
+type deniedKeyContextKey struct{}
+
+func (a *authConfig) allowKey(ctx ssh.Context, key ssh.PublicKey) bool {
+	if a.isDenied(key) {
+		log.Printf("Denied key %s for %s from %s", gossh.FingerprintSHA256(key), ctx.User(), ctx.RemoteAddr())
+		ctx.SetValue(deniedKeyContextKey{}, true)
+		return false
+	}
+	return a.Mode != authMembers || a.isMember(ctx.User(), key)
+}
+
+func publicKeyHandler(ctx ssh.Context, key ssh.PublicKey) bool {
+	return settings().Auth.allowKey(ctx, key)
+}
+
+// keyboardInteractiveHandler lets in clients without a key as guests, unless they've
+// already offered a denied key
+func keyboardInteractiveHandler(ctx ssh.Context, challenger gossh.KeyboardInteractiveChallenge) bool {
+	if denied, _ := ctx.Value(deniedKeyContextKey{}).(bool); denied {
+		return false
+	}
+	return settings().Auth.Mode != authMembers
+}
+
+// isGuest reports whether the session has restricted access
+func (a *authConfig) isGuest(s ssh.Session) bool {
+	return a.Mode == authGuest && !a.isMember(s.User(), s.PublicKey())
+}
+
+// restrict applies the guest restrictions to a new session's model. Guests can't leave
+// anything behind on the server, so they can't export snapshots or save a profile.
+func (a *authConfig) restrict(m *model) {
+	m.guest = true
+	m.canExport = false
+	m.locationLocked = a.GuestFixedLocation
+	if a.GuestMaxRange != 0 {
+		m.maxRadarRange = a.GuestMaxRange
+		m.radarRange = min(m.radarRange, m.maxRadarRange)
+	}
+}
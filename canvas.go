@@ -0,0 +1,390 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Radar renderers, cycled with r. cells draws one position per character, braille and
+// halfblock draw on a canvas of 2×4 or 1×2 pixels per character for a smoother scope and
+// more precise plane positions.
+const (
+	rendererCells     = "cells"
+	rendererBraille   = "braille"
+	rendererHalfBlock = "halfblock"
+)
+
+var radarRenderers = []string{rendererCells, rendererBraille, rendererHalfBlock}
+
+// sweepStep is how far the sweep arm turns each tick, in radians
+const sweepStep = 0.1
+
+// pixelLevel is what a canvas pixel shows, brighter levels drawing over dimmer ones
+type pixelLevel int
+
+const (
+	pixelOff pixelLevel = iota
+	pixelSector
+	pixelRing
+	pixelDimmest
+	pixelDim
+	pixelMedium
+	pixelBright
+)
+
+// pixelDensities are the share of pixels lit at each level on limited colour terminals,
+// where the fade is drawn by dithering
+var pixelDensities = map[pixelLevel]float64{
+	pixelSector:  1.0 / 16,
+	pixelRing:    1,
+	pixelDimmest: 1.0 / 8,
+	pixelDim:     1.0 / 4,
+	pixelMedium:  1.0 / 2,
+	pixelBright:  1,
+}
+
+// bayer4 orders pixels for dithering so partial densities are spread evenly
+var bayer4 = [4][4]float64{
+	{0, 8, 2, 10},
+	{12, 4, 14, 6},
+	{3, 11, 1, 9},
+	{15, 7, 13, 5},
+}
+
+type pixel struct {
+	level pixelLevel
+	// solid pixels, rings and planes, replace whatever is under them and are never dithered
+	solid bool
+	// inSector marks planes that can be seen from the observer's viewing sectors
+	inSector bool
+}
+
+// canvas is the radar rasterised at cols×rows pixels per character
+type canvas struct {
+	cols, rows    int
+	width, height int
+	pixels        []pixel
+}
+
+func newCanvas(cellsWide int, cellsHigh int, cols int, rows int) *canvas {
+	return &canvas{
+		cols:   cols,
+		rows:   rows,
+		width:  cellsWide * cols,
+		height: cellsHigh * rows,
+		pixels: make([]pixel, cellsWide*cols*cellsHigh*rows),
+	}
+}
+
+func (cv *canvas) at(x int, y int) pixel {
+	if !inBounds(cv.width, cv.height, x, y) {
+		return pixel{}
+	}
+	return cv.pixels[y*cv.width+x]
+}
+
+func (cv *canvas) set(x int, y int, p pixel) {
+	if !inBounds(cv.width, cv.height, x, y) {
+		return
+	}
+	current := &cv.pixels[y*cv.width+x]
+	if p.solid || !current.solid && p.level > current.level {
+		*current = p
+	}
+}
+
+// lit reports whether a pixel is drawn on a limited colour terminal
+func (cv *canvas) lit(x int, y int) bool {
+	p := cv.at(x, y)
+	if p.level == pixelOff {
+		return false
+	}
+	return p.solid || bayer4[y%4][x%4]/16 < pixelDensities[p.level]
+}
+
+// sweepLevel fades the sweep trail over the ticks since the arm passed
+func sweepLevel(age float64) pixelLevel {
+	switch {
+	case age <= 2:
+		return pixelBright
+	case age <= 7:
+		return pixelMedium
+	case age <= 12:
+		return pixelDim
+	}
+	return pixelOff
+}
+
+// planeLevel fades a plane over the ticks since the arm passed it, slower than the sweep
+func planeLevel(age float64) pixelLevel {
+	switch {
+	case age <= 15:
+		return pixelBright
+	case age <= 30:
+		return pixelMedium
+	case age <= 60:
+		return pixelDim
+	}
+	return pixelDimmest
+}
+
+// sweepAge is how many ticks ago the arm passed a display bearing
+func (m *model) sweepAge(bearing float64) float64 {
+	return math.Mod(math.Mod(m.sweepAngle-bearing, 2*math.Pi)+2*math.Pi, 2*math.Pi) / sweepStep
+}
+
+// rasterizeRadar draws the viewing sectors, sweep trail, range rings, bezel and planes. Pixel
+// positions are in characters from the top left, so a character x covers x to x+1.
+func (m *model) rasterizeRadar(ctx radarContext, cv *canvas) {
+	sx, sy := float64(cv.cols), float64(cv.rows)
+	cx, cy := float64(ctx.cx)+0.5, float64(ctx.cy)+0.5
+	// The size of a pixel in the radar's radius units, so the edge is at least a pixel wide
+	pixelR := max(1/sx, 1/(sy*m.aspectRatio))
+
+	var rings []float64
+	step := m.rangeRingStep(ctx)
+	for d := step; d < float64(m.radarRange); d += step {
+		rings = append(rings, d*m.radarScale(ctx))
+	}
+
+	for py := 0; py < cv.height; py++ {
+		for px := 0; px < cv.width; px++ {
+			dx := (float64(px)+0.5)/sx - cx
+			dy := (cy - (float64(py)+0.5)/sy) / m.aspectRatio
+			dist := math.Hypot(dx, dy)
+			if dist > ctx.r {
+				continue
+			}
+			if dist > ctx.r-pixelR {
+				cv.set(px, py, pixel{level: pixelRing, solid: true})
+				continue
+			}
+
+			for _, ring := range rings {
+				if math.Abs(dist-ring) < pixelR/2 {
+					cv.set(px, py, pixel{level: pixelRing})
+				}
+			}
+			bearing := math.Atan2(dx, dy)
+			for _, v := range m.viewingSectors {
+				if v.containsAzimuth(bearing + m.northOffset) {
+					cv.set(px, py, pixel{level: pixelSector})
+					break
+				}
+			}
+			cv.set(px, py, pixel{level: sweepLevel(m.sweepAge(bearing))})
+		}
+	}
+
+	// Bezel ticks every 10° point in from the edge, longer every 30°
+	for deg := 0; deg < 360; deg += 10 {
+		phi := float64(deg)*math.Pi/180 - m.northOffset
+		length := 0.75
+		if deg%30 == 0 {
+			length = 1.5
+		}
+		for l := ctx.r - length; l < ctx.r; l += pixelR / 2 {
+			x := (cx + l*math.Sin(phi)) * sx
+			y := (cy - l*math.Cos(phi)*m.aspectRatio) * sy
+			cv.set(int(x), int(y), pixel{level: pixelRing, solid: true})
+		}
+	}
+
+	scale := m.radarScale(ctx)
+	for _, p := range m.planes {
+		if p.DistanceFromObserver > float64(m.radarRange) {
+			continue
+		}
+		bearing := p.BearingFromObserver - m.northOffset
+		distance := p.DistanceFromObserver * scale
+		x := (cx + distance*math.Sin(bearing)) * sx
+		y := (cy - distance*math.Cos(bearing)*m.aspectRatio) * sy
+		level := planeLevel(m.sweepAge(bearing))
+
+		// A short tail behind the plane shows which way it's heading
+		heading := p.Heading*math.Pi/180 - m.northOffset
+		for i := cv.cols + 1; i >= 1; i-- {
+			step := float64(i) * pixelR
+			tx := x - step*math.Sin(heading)*sx
+			ty := y + step*math.Cos(heading)*m.aspectRatio*sy
+			cv.set(int(tx), int(ty), pixel{level: max(level-1, pixelDimmest), solid: true})
+		}
+		// Braille blips are 2×2 pixels so they're easy to spot, half blocks a single pixel
+		size := cv.cols
+		for by := 0; by < size; by++ {
+			for bx := 0; bx < size; bx++ {
+				cv.set(int(x-float64(size)/2+0.5)+bx, int(y-float64(size)/2+0.5)+by, pixel{level: level, solid: true, inSector: p.InSector})
+			}
+		}
+	}
+}
+
+// highlightPlane makes planes in the viewing sectors stand out as the cells renderer does,
+// underlined, and bold where bold isn't already used for fading
+func highlightPlane(style lipgloss.Style, limited bool) lipgloss.Style {
+	if limited {
+		return style.Underline(true)
+	}
+	return style.Bold(true).Underline(true)
+}
+
+func (m *model) levelColour(level pixelLevel) lipgloss.Color {
+	switch level {
+	case pixelSector:
+		return lipgloss.Color(m.theme.Sector)
+	case pixelRing:
+		return lipgloss.Color(m.theme.Frame)
+	case pixelDimmest:
+		return lipgloss.Color(m.theme.Dimmest)
+	case pixelDim:
+		return lipgloss.Color(m.theme.Dim)
+	case pixelMedium:
+		return lipgloss.Color(m.theme.Medium)
+	case pixelBright:
+		return lipgloss.Color(m.theme.Bright)
+	}
+	return lipgloss.Color("")
+}
+
+// brailleDots are the bits of the Braille pattern for each pixel of a 2×4 cell
+var brailleDots = [4][2]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+// brailleGlyph draws one character of the canvas as a Braille pattern. A character only
+// has one colour, so it takes the brightest of its pixels, with viewing sectors shaded in
+// the background.
+func (m *model) brailleGlyph(cv *canvas, x int, y int, limited bool) string {
+	var dots rune
+	brightest := pixelOff
+	inSector, planeInSector := false, false
+	for dy := 0; dy < 4; dy++ {
+		for dx := 0; dx < 2; dx++ {
+			px, py := x*2+dx, y*4+dy
+			p := cv.at(px, py)
+			if p.level == pixelSector {
+				inSector = true
+			}
+			planeInSector = planeInSector || p.inSector
+			if limited && !cv.lit(px, py) || !limited && (p.level == pixelOff || p.level == pixelSector) {
+				continue
+			}
+			dots |= brailleDots[dy][dx]
+			brightest = max(brightest, p.level)
+		}
+	}
+
+	style := m.renderer.NewStyle().Foreground(m.levelColour(brightest))
+	if inSector && !limited {
+		style = style.Background(m.levelColour(pixelSector))
+	}
+	if planeInSector {
+		style = highlightPlane(style, limited)
+	}
+	if dots == 0 {
+		if inSector && !limited {
+			return style.Render(" ")
+		}
+		return " "
+	}
+	return style.Render(string(0x2800 + dots))
+}
+
+// halfBlockGlyph draws one character of the canvas as its top and bottom pixels, using the
+// foreground and background colours for the two halves
+func (m *model) halfBlockGlyph(cv *canvas, x int, y int, limited bool) string {
+	top, bottom := cv.at(x, y*2), cv.at(x, y*2+1)
+	topOn, bottomOn := top.level != pixelOff, bottom.level != pixelOff
+	if limited {
+		topOn, bottomOn = cv.lit(x, y*2), cv.lit(x, y*2+1)
+	}
+
+	style := m.renderer.NewStyle()
+	if top.inSector || bottom.inSector {
+		style = highlightPlane(style, limited)
+	}
+	switch {
+	case topOn && bottomOn && (limited || top.level == bottom.level):
+		return style.Foreground(m.levelColour(max(top.level, bottom.level))).Render("█")
+	case topOn && bottomOn:
+		return style.Foreground(m.levelColour(top.level)).Background(m.levelColour(bottom.level)).Render("▀")
+	case topOn:
+		return style.Foreground(m.levelColour(top.level)).Render("▀")
+	case bottomOn:
+		return style.Foreground(m.levelColour(bottom.level)).Render("▄")
+	}
+	return " "
+}
+
+// renderCanvas draws the radar with the Braille or half block renderer. Labels and the
+// heard position are drawn as text over the canvas.
+func (m *model) renderCanvas(ctx radarContext) string {
+	cols, rows := 2, 4
+	glyph := m.brailleGlyph
+	if m.radarRenderer == rendererHalfBlock {
+		cols, rows = 1, 2
+		glyph = m.halfBlockGlyph
+	}
+	cv := newCanvas(ctx.width, ctx.height, cols, rows)
+	m.rasterizeRadar(ctx, cv)
+
+	ctx.cells = newCellGrid(ctx.width, ctx.height)
+	m.renderDistanceLabels(ctx)
+	if m.acousticMode {
+		m.renderHeardPosition(ctx)
+	}
+	m.renderBearingLabels(ctx)
+
+	limited := m.limitedColors()
+	heard := m.renderer.NewStyle().Bold(true).Foreground(lipgloss.Color(m.theme.Heard))
+	var b strings.Builder
+	for y, row := range ctx.cells {
+		// No newline after the last row, so the status bar doesn't push the top off screen
+		if y > 0 {
+			b.WriteByte('\n')
+		}
+		for x, c := range row {
+			switch c.kind {
+			case "heard":
+				b.WriteString(heard.Render(string(c.char)))
+			case "label":
+				b.WriteRune(c.char)
+			default:
+				b.WriteString(glyph(cv, x, y, limited))
+			}
+		}
+	}
+	return b.String()
+}
+
+func newCellGrid(width int, height int) [][]cell {
+	cells := make([][]cell, height)
+	for y := range cells {
+		cells[y] = make([]cell, width)
+		for x := range cells[y] {
+			cells[y][x] = cell{' ', "blank", int(100), false}
+		}
+	}
+	return cells
+}
+
+func parseRadarRenderer(s string) (string, error) {
+	if !slices.Contains(radarRenderers, s) {
+		return "", fmt.Errorf("unknown renderer %q, expected %s", s, strings.Join(radarRenderers, ", "))
+	}
+	return s, nil
+}
+
+// cycleRadarRenderer switches to the renderer after the current one
+func (m *model) cycleRadarRenderer() {
+	next := (slices.Index(radarRenderers, m.radarRenderer) + 1) % len(radarRenderers)
+	m.radarRenderer = radarRenderers[next]
+	m.statusMessage = "Renderer: " + m.radarRenderer
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// colorProfiles are the values --colors takes, auto detecting from the client's TERM,
+// COLORTERM and NO_COLOR
+var colorProfiles = map[string]termenv.Profile{
+	"truecolor": termenv.TrueColor,
+	"256":       termenv.ANSI256,
+	"16":        termenv.ANSI,
+	"none":      termenv.Ascii,
+}
+
+func parseColorProfile(s string) (termenv.Profile, error) {
+	p, ok := colorProfiles[s]
+	if !ok {
+		return 0, fmt.Errorf("unknown colors %q, expected auto, truecolor, 256, 16 or none", s)
+	}
+	return p, nil
+}
+
+// setRenderer draws the model with r, at the colour profile r detected for its terminal
+func (m *model) setRenderer(r *lipgloss.Renderer) {
+	m.renderer = r
+	m.setColorProfile(r.ColorProfile())
+}
+
+func (m *model) setColorProfile(p termenv.Profile) {
+	m.colors = p
+	// Without colour keep the ANSI profile, just with no colours in the theme, so bold,
+	// faint and reverse still mark the sweep and the selected row
+	if p == termenv.Ascii {
+		p = termenv.ANSI
+	}
+	m.renderer.SetColorProfile(p)
+	placeholder := m.renderer.NewStyle().Foreground(lipgloss.Color("240"))
+	if m.colors == termenv.Ascii {
+		placeholder = m.renderer.NewStyle().Faint(true)
+	}
+	for _, input := range append(m.modalInputs(), &m.sectorInput) {
+		input.PlaceholderStyle = placeholder
+		input.Cursor.Style = m.renderer.NewStyle()
+	}
+	m.setTheme(m.themeName)
+}
+
+// limitedColors reports whether the terminal has too few colours to show the sweep fading,
+// so it's drawn with shading characters instead
+func (m *model) limitedColors() bool {
+	return m.colors == termenv.ANSI || m.colors == termenv.Ascii
+}
+
+// fadeShade is the shading character for a sweep cell in limited colour mode
+func fadeShade(sweepAge int) rune {
+	switch {
+	case sweepAge <= 2:
+		return '▓'
+	case sweepAge <= 7:
+		return '▒'
+	case sweepAge <= 12:
+		return '░'
+	}
+	return ' '
+}
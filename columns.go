@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+)
+
+// planeColumns are every column of the plane table, in the order they're shown
+var planeColumns = []table.Column{
+	{Title: "FLT", Width: 8},
+	{Title: "AIRLINE", Width: 16},
+	{Title: "ORIGIN", Width: 14},
+	{Title: "DEST", Width: 14},
+	{Title: "DIST(NM)", Width: 9},
+	{Title: "EL", Width: 4},
+	{Title: "SLANT", Width: 6},
+	{Title: "dBA", Width: 4},
+	{Title: "CPA(NM)", Width: 12},
+	{Title: "VIEW", Width: 4},
+}
+
+// narrowColumns are the text columns that can be narrowed to fit, and how narrow they can
+// go. The table cuts off longer names with an ellipsis.
+var narrowColumns = map[string]int{"AIRLINE": 7, "ORIGIN": 5, "DEST": 5}
+
+// droppedColumns are left out, first to last, once the text columns are as narrow as they
+// can go and the table still doesn't fit beside the radar
+var droppedColumns = []string{"VIEW", "SLANT", "EL", "dBA", "CPA(NM)"}
+
+// fitColumns returns the indexes of the planeColumns that fit in width characters, counting
+// each cell's padding and the table's border, and the columns narrowed to fit
+func fitColumns(width int) ([]int, []table.Column) {
+	for n := 0; ; n++ {
+		var keep []int
+		var columns []table.Column
+		tableWidth := 2
+		for i, column := range planeColumns {
+			if slices.Contains(droppedColumns[:n], column.Title) {
+				continue
+			}
+			keep = append(keep, i)
+			columns = append(columns, column)
+			tableWidth += column.Width + 2
+		}
+
+		// Take a character at a time from the widest text column
+		for tableWidth > width {
+			widest := -1
+			for i, column := range columns {
+				minWidth, ok := narrowColumns[column.Title]
+				if ok && column.Width > minWidth && (widest < 0 || column.Width > columns[widest].Width) {
+					widest = i
+				}
+			}
+			if widest < 0 {
+				break
+			}
+			columns[widest].Width--
+			tableWidth--
+		}
+
+		if tableWidth <= width || n == len(droppedColumns) {
+			return keep, columns
+		}
+	}
+}
+
+// layoutTable shows the columns that fit in half the terminal
+func (m *model) layoutTable() {
+	var columns []table.Column
+	m.tableColumns, columns = fitColumns(m.width / 2)
+	tableWidth := 0
+	for _, column := range columns {
+		tableWidth += column.Width + 2
+	}
+	// Rows must never have fewer cells than there are columns
+	m.tbl.SetRows(nil)
+	m.tbl.SetColumns(columns)
+	m.tbl.SetWidth(tableWidth)
+	m.setTableRows(m.tableRows)
+}
+
+// planeRow is the table row for p, with a cell for every one of planeColumns
+func planeRow(p plane, now time.Time) table.Row {
+	return table.Row{
+		p.FlightCode,
+		p.RouteInfo.Airline,
+		p.RouteInfo.OriginMunicipality,
+		p.RouteInfo.DestMunicipality,
+		fmt.Sprintf("%.2f", p.DistanceFromObserver),
+		formatElevation(p),
+		formatSlantRange(p),
+		formatNoise(p),
+		formatCPA(p, now),
+		formatInSector(p),
+	}
+}
+
+// refreshTableRows rebuilds the rows of the planes already in the table, keeping their order,
+// for when something shown for every plane changes
+func (m *model) refreshTableRows() {
+	now := time.Now()
+	rows := make([]table.Row, len(m.tableRows))
+	for i, row := range m.tableRows {
+		rows[i] = row
+		for _, p := range m.planes {
+			if p.FlightCode == row[0] {
+				rows[i] = planeRow(p, now)
+				break
+			}
+		}
+	}
+	m.setTableRows(rows)
+}
+
+// setTableRows stores rows with a cell for every one of planeColumns and shows the cells
+// of the columns that fit
+func (m *model) setTableRows(rows []table.Row) {
+	m.tableRows = rows
+	visible := make([]table.Row, len(rows))
+	for i, row := range rows {
+		for _, c := range m.tableColumns {
+			visible[i] = append(visible[i], row[c])
+		}
+	}
+	m.tbl.SetRows(visible)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFitColumns(t *testing.T) {
+	tests := []struct {
+		width   int
+		dropped []string
+		narrow  bool
+	}{
+		{width: 200},
+		{width: 113},
+		{width: 112, narrow: true},
+		{width: 86, narrow: true},
+		{width: 85, dropped: []string{"VIEW"}, narrow: true},
+		{width: 80, dropped: []string{"VIEW"}, narrow: true},
+		{width: 72, dropped: []string{"VIEW", "SLANT"}, narrow: true},
+		{width: 60, dropped: []string{"VIEW", "SLANT", "EL", "dBA"}, narrow: true},
+		{width: 40, dropped: droppedColumns, narrow: true},
+	}
+	for _, tt := range tests {
+		keep, columns := fitColumns(tt.width)
+		if len(keep) != len(columns) {
+			t.Fatalf("fitColumns(%d) kept %d indexes but %d columns", tt.width, len(keep), len(columns))
+		}
+
+		var titles, dropped []string
+		tableWidth, narrowed := 2, false
+		for i, column := range columns {
+			full := planeColumns[keep[i]]
+			if column.Title != full.Title {
+				t.Errorf("fitColumns(%d) column %d is %s, want %s", tt.width, i, column.Title, full.Title)
+			}
+			if column.Width < full.Width {
+				narrowed = true
+				if _, ok := narrowColumns[column.Title]; !ok {
+					t.Errorf("fitColumns(%d) narrowed %s, which isn't a text column", tt.width, column.Title)
+				}
+			}
+			titles = append(titles, column.Title)
+			tableWidth += column.Width + 2
+		}
+		for _, column := range planeColumns {
+			if !slices.Contains(titles, column.Title) {
+				dropped = append(dropped, column.Title)
+			}
+		}
+
+		if !slices.Equal(dropped, orderedAsPlaneColumns(tt.dropped)) {
+			t.Errorf("fitColumns(%d) dropped %v, want %v", tt.width, dropped, tt.dropped)
+		}
+		if narrowed != tt.narrow {
+			t.Errorf("fitColumns(%d) narrowed text columns: %v, want %v", tt.width, narrowed, tt.narrow)
+		}
+		if len(dropped) < len(droppedColumns) && tableWidth > tt.width {
+			t.Errorf("fitColumns(%d) is %d wide", tt.width, tableWidth)
+		}
+	}
+}
+
+// orderedAsPlaneColumns sorts titles into the order of planeColumns
+func orderedAsPlaneColumns(titles []string) []string {
+	var ordered []string
+	for _, column := range planeColumns {
+		if slices.Contains(titles, column.Title) {
+			ordered = append(ordered, column.Title)
+		}
+	}
+	return ordered
+}
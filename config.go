@@ -0,0 +1,450 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// configEnvPrefix is the prefix of environment variables overriding the config file, named
+// after the section and key, e.g. WPIT_SERVER_PORT or WPIT_DEFAULTS_LAT
+const configEnvPrefix = "WPIT_"
+
+// maxAPIRange is the largest radius adsb.lol's point API accepts
+const maxAPIRange = 250
+
+type serverConfig struct {
+	Host         string `toml:"host" yaml:"host"`
+	Port         string `toml:"port" yaml:"port"`
+	HostKey      string `toml:"host_key" yaml:"host_key"`
+	HostKeyDir   string `toml:"host_key_dir" yaml:"host_key_dir"`
+	HostKeyTypes string `toml:"host_key_types" yaml:"host_key_types"`
+	Stream       string `toml:"stream" yaml:"stream"`
+	History      string `toml:"history" yaml:"history"`
+	Profiles     string `toml:"profiles" yaml:"profiles"`
+	GeoIP        string `toml:"geoip" yaml:"geoip"`
+	ExportDir    string `toml:"export_dir" yaml:"export_dir"`
+	// SessionExport lets SSH sessions write snapshots to ExportDir with e/E
+	SessionExport bool `toml:"session_export" yaml:"session_export"`
+	// StreamOrigins are the comma separated web page origins allowed to subscribe to the
+	// stream, besides pages served from the stream's own host
+	StreamOrigins string `toml:"stream_origins" yaml:"stream_origins"`
+}
+
+type sourcesConfig struct {
+	ADSBURL       string        `toml:"adsb_url" yaml:"adsb_url"`
+	RouteURL      string        `toml:"route_url" yaml:"route_url"`
+	RouteCacheTTL time.Duration `toml:"route_cache_ttl" yaml:"route_cache_ttl"`
+	PollInterval  time.Duration `toml:"poll_interval" yaml:"poll_interval"`
+}
+
+type defaultsConfig struct {
+	Lat          float64       `toml:"lat" yaml:"lat"`
+	Lon          float64       `toml:"lon" yaml:"lon"`
+	Range        int           `toml:"range" yaml:"range"`
+	MaxRange     int           `toml:"max_range" yaml:"max_range"`
+	AspectRatio  float64       `toml:"aspect_ratio" yaml:"aspect_ratio"`
+	TickInterval time.Duration `toml:"tick_interval" yaml:"tick_interval"`
+	Theme        string        `toml:"theme" yaml:"theme"`
+	Renderer     string        `toml:"renderer" yaml:"renderer"`
+}
+
+// dataConfig names the reference datasets. Any left empty are read from Dir, where
+// "whatplaneisthat import" puts them, falling back to the bundled ones.
+type dataConfig struct {
+	Dir      string `toml:"dir" yaml:"dir"`
+	Airports string `toml:"airports" yaml:"airports"`
+	Places   string `toml:"places" yaml:"places"`
+	Airlines string `toml:"airlines" yaml:"airlines"`
+	Aircraft string `toml:"aircraft" yaml:"aircraft"`
+}
+
+// config is everything that can be set in the config file. Server and data settings need a
+// restart to change; the rest are reloaded on SIGHUP.
+type config struct {
+	Server   serverConfig   `toml:"server" yaml:"server"`
+	Auth     authConfig     `toml:"auth" yaml:"auth"`
+	Limits   limitConfig    `toml:"limits" yaml:"limits"`
+	Sources  sourcesConfig  `toml:"sources" yaml:"sources"`
+	Defaults defaultsConfig `toml:"defaults" yaml:"defaults"`
+	Data     dataConfig     `toml:"data" yaml:"data"`
+	// Themes adds themes, or replaces built in ones, by name
+	Themes map[string]theme `toml:"themes" yaml:"themes"`
+}
+
+func defaultConfig() *config {
+	return &config{
+		Server: serverConfig{
+			Port:         "22",
+			HostKey:      defaultHostKey,
+			HostKeyDir:   "host_keys",
+			HostKeyTypes: "ed25519",
+			History:      "sightings.db",
+			Profiles:     "profiles.db",
+			ExportDir:    "exports",
+		},
+		Auth: authConfig{Mode: authOpen},
+		Limits: limitConfig{
+			MaxSessions:          50,
+			MaxSessionsPerIP:     5,
+			MaxSessionsPerKey:    3,
+			ConnectionsPerMinute: 20,
+			IdleTimeout:          30 * time.Minute,
+			MaxStreams:           20,
+			MaxStreamsPerIP:      5,
+			MaxFeedLocations:     100,
+		},
+		Sources: sourcesConfig{
+			ADSBURL:       "https://api.adsb.lol/v2/point",
+			RouteURL:      "https://api.adsbdb.com/v0/callsign",
+			RouteCacheTTL: 10 * time.Minute,
+			PollInterval:  10 * time.Second,
+		},
+		Defaults: defaultsConfig{
+			Lat:          53.79538,
+			Lon:          -1.66134,
+			Range:        15,
+			MaxRange:     200,
+			AspectRatio:  0.5,
+			TickInterval: 200 * time.Millisecond,
+			Theme:        defaultTheme,
+			Renderer:     rendererCells,
+		},
+		Data: dataConfig{Dir: "datasets"},
+	}
+}
+
+var currentConfig atomic.Pointer[config]
+
+func init() {
+	currentConfig.Store(defaultConfig())
+}
+
+// settings returns the configuration in effect, which may be replaced by a reload at any time
+func settings() *config {
+	return currentConfig.Load()
+}
+
+// registerConfigFlags adds the command line flags overriding the config file
+func registerConfigFlags(fs *flag.FlagSet, c *config) {
+	fs.StringVar(&c.Server.Host, "host", c.Server.Host, "Host to listen on (default: all interfaces)")
+	fs.StringVar(&c.Server.Port, "port", c.Server.Port, "Port to listen on")
+	fs.StringVar(&c.Server.HostKey, "host-key", c.Server.HostKey, "Host key file, used instead of the key of its type in --host-key-dir")
+	fs.StringVar(&c.Server.HostKeyDir, "host-key-dir", c.Server.HostKeyDir, "Directory host keys are kept in, generated on first start")
+	fs.StringVar(&c.Server.HostKeyTypes, "host-key-types", c.Server.HostKeyTypes, "Comma separated host key types to serve: ed25519, ecdsa and rsa")
+	fs.StringVar(&c.Server.Stream, "stream", c.Server.Stream, "Address for the WebSocket stream, e.g. :8080 (default: disabled)")
+	fs.StringVar(&c.Server.StreamOrigins, "stream-origins", c.Server.StreamOrigins, "Comma separated web page origins allowed to use the stream, e.g. https://example.com, or * for any")
+	fs.StringVar(&c.Server.History, "history", c.Server.History, "SQLite database to record sightings in (empty to disable)")
+	fs.StringVar(&c.Server.Profiles, "profiles", c.Server.Profiles, "SQLite database to keep per public key profiles in (empty to disable)")
+	fs.StringVar(&c.Server.GeoIP, "geoip", c.Server.GeoIP, "MaxMind-format City mmdb used to start visitors at their own location (default: disabled)")
+	fs.StringVar(&c.Server.ExportDir, "export-dir", c.Server.ExportDir, "Directory for snapshots exported with e/E")
+	fs.BoolVar(&c.Server.SessionExport, "session-export", c.Server.SessionExport, "Let SSH sessions export snapshots to --export-dir with e/E")
+	fs.StringVar(&c.Data.Airports, "airports", c.Data.Airports, "OurAirports airports.csv to search instead of the bundled subset")
+	fs.StringVar(&c.Data.Places, "places", c.Data.Places, "Places CSV (name,country,latitude,longitude,population) to search instead of the bundled gazetteer")
+	fs.StringVar(&c.Data.Airlines, "airlines", c.Data.Airlines, "Airlines CSV (icao,name) naming airlines the route lookup doesn't know")
+	fs.StringVar(&c.Data.Aircraft, "aircraft", c.Data.Aircraft, "Aircraft types CSV (type,noise_class) used to estimate noise")
+	fs.StringVar(&c.Data.Dir, "data-dir", c.Data.Dir, "Directory datasets are imported into")
+	fs.StringVar(&c.Auth.Mode, "auth", c.Auth.Mode, "Who can connect: open, guest (everyone, non-members restricted) or members")
+	fs.StringVar(&c.Auth.AuthorizedKeys, "authorized-keys", c.Auth.AuthorizedKeys, "authorized_keys file listing member keys")
+	fs.StringVar(&c.Auth.UserKeysDir, "user-keys-dir", c.Auth.UserKeysDir, "Directory of <user>.keys files listing each member's keys")
+	fs.StringVar(&c.Auth.DeniedKeys, "denied-keys", c.Auth.DeniedKeys, "authorized_keys format file of keys that can never connect")
+	fs.IntVar(&c.Auth.GuestMaxRange, "guest-max-range", c.Auth.GuestMaxRange, "Largest radar range in NM guests can use (default: no limit)")
+	fs.BoolVar(&c.Auth.GuestFixedLocation, "guest-fixed-location", c.Auth.GuestFixedLocation, "Keep guests at the default location")
+	fs.IntVar(&c.Limits.MaxSessions, "max-sessions", c.Limits.MaxSessions, "Most sessions open at once (0 for no limit)")
+	fs.IntVar(&c.Limits.MaxSessionsPerIP, "max-sessions-per-ip", c.Limits.MaxSessionsPerIP, "Most sessions open at once from one IP address (0 for no limit)")
+	fs.IntVar(&c.Limits.MaxSessionsPerKey, "max-sessions-per-key", c.Limits.MaxSessionsPerKey, "Most sessions open at once with one public key (0 for no limit)")
+	fs.IntVar(&c.Limits.ConnectionsPerMinute, "connections-per-minute", c.Limits.ConnectionsPerMinute, "Most new sessions per minute from one IP address (0 for no limit)")
+	fs.DurationVar(&c.Limits.IdleTimeout, "idle-timeout", c.Limits.IdleTimeout, "Disconnect sessions with no input for this long (0 to never)")
+	fs.IntVar(&c.Limits.MaxStreams, "max-streams", c.Limits.MaxStreams, "Most stream subscribers at once (0 for no limit)")
+	fs.IntVar(&c.Limits.MaxStreamsPerIP, "max-streams-per-ip", c.Limits.MaxStreamsPerIP, "Most stream subscribers at once from one IP address (0 for no limit)")
+	fs.IntVar(&c.Limits.MaxFeedLocations, "max-feed-locations", c.Limits.MaxFeedLocations, "Most locations polled upstream at once (0 for no limit)")
+}
+
+// decodeConfigFile reads a TOML or YAML config file, chosen by its extension, rejecting
+// keys that don't exist so typos aren't silently ignored
+func decodeConfigFile(path string, c *config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		meta, err := toml.Decode(string(data), c)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("%s: unknown setting %s", path, undecoded[0])
+		}
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	default:
+		return fmt.Errorf("%s: config files must end in .toml, .yaml or .yml", path)
+	}
+	return nil
+}
+
+// applyConfigEnv sets every config field with a WPIT_<SECTION>_<KEY> environment variable
+func applyConfigEnv(c *config, environ []string) error {
+	env := make(map[string]string)
+	for _, kv := range environ {
+		if key, value, ok := strings.Cut(kv, "="); ok && strings.HasPrefix(key, configEnvPrefix) {
+			env[key] = value
+		}
+	}
+
+	sections := reflect.ValueOf(c).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		section := sections.Field(i)
+		if section.Kind() != reflect.Struct {
+			continue
+		}
+		sectionName := sections.Type().Field(i).Tag.Get("toml")
+		for j := 0; j < section.NumField(); j++ {
+			field := section.Field(j)
+			name := strings.ToUpper(configEnvPrefix + sectionName + "_" + section.Type().Field(j).Tag.Get("toml"))
+			value, ok := env[name]
+			if !ok {
+				continue
+			}
+			if err := setConfigField(field, value); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func setConfigField(field reflect.Value, value string) error {
+	switch field.Interface().(type) {
+	case string:
+		field.SetString(value)
+	case time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+	case int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(n))
+	case float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
+
+// readConfig reads the config file at path, if any, over the defaults and then applies
+// WPIT_* environment variables
+func readConfig(path string) (*config, error) {
+	c := defaultConfig()
+	if path != "" {
+		if err := decodeConfigFile(path, c); err != nil {
+			return nil, err
+		}
+	}
+	if err := applyConfigEnv(c, os.Environ()); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// useConfigFile reads, validates and applies the config for commands that run without the
+// server, which only take --config rather than every server flag
+func useConfigFile(path string) (*config, error) {
+	c, err := readConfig(path)
+	if err == nil {
+		err = c.validate()
+	}
+	if err != nil {
+		return nil, err
+	}
+	currentConfig.Store(c)
+	exportDir = c.Server.ExportDir
+	useDataConfig(c.Data)
+	return c, nil
+}
+
+// addConfigFlag adds --config, defaulting to $WPIT_CONFIG, to a command's flags
+func addConfigFlag(fs *flag.FlagSet) *string {
+	return fs.String("config", os.Getenv(configEnvPrefix+"CONFIG"), "TOML or YAML config file (default: $WPIT_CONFIG)")
+}
+
+// loadConfig builds the configuration from the defaults, the config file given by --config
+// or WPIT_CONFIG, WPIT_* environment variables and then command line flags, each overriding
+// the last. It returns the config file path, which is empty when there isn't one.
+func loadConfig(name string, args []string) (*config, string, error) {
+	newFlagSet := func(c *config) (*flag.FlagSet, *string) {
+		fs := flag.NewFlagSet(name, flag.ContinueOnError)
+		registerConfigFlags(fs, c)
+		return fs, addConfigFlag(fs)
+	}
+
+	// Parse the flags once to find the config file, then again over it
+	fs, path := newFlagSet(defaultConfig())
+	if err := fs.Parse(args); err != nil {
+		return nil, "", err
+	}
+
+	c, err := readConfig(*path)
+	if err != nil {
+		return nil, *path, err
+	}
+	fs, _ = newFlagSet(c)
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return nil, *path, err
+	}
+	return c, *path, nil
+}
+
+func validateURL(name string, s string) error {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s %q must be an http or https URL", name, s)
+	}
+	return nil
+}
+
+func (c *config) validate() error {
+	var errs []error
+	check := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port < 1 || port > 65535 {
+		check(fmt.Errorf("server port %q must be between 1 and 65535", c.Server.Port))
+	}
+	for _, t := range strings.Split(c.Server.HostKeyTypes, ",") {
+		if _, ok := hostKeyTypes[strings.ToLower(strings.TrimSpace(t))]; !ok {
+			check(fmt.Errorf("unknown host key type %q, expected ed25519, ecdsa or rsa", t))
+		}
+	}
+
+	check(validateURL("sources adsb_url", c.Sources.ADSBURL))
+	check(validateURL("sources route_url", c.Sources.RouteURL))
+	if c.Sources.RouteCacheTTL < 0 {
+		check(fmt.Errorf("sources route_cache_ttl can't be negative"))
+	}
+	if c.Sources.PollInterval < time.Second {
+		check(fmt.Errorf("sources poll_interval must be at least 1s"))
+	}
+
+	d := c.Defaults
+	if d.Lat < -90 || d.Lat > 90 {
+		check(fmt.Errorf("defaults lat must be between -90 and 90"))
+	}
+	if d.Lon < -180 || d.Lon > 180 {
+		check(fmt.Errorf("defaults lon must be between -180 and 180"))
+	}
+	if d.MaxRange < MIN_RADAR_RANGE || d.MaxRange > maxAPIRange {
+		check(fmt.Errorf("defaults max_range must be between %d and %d NM", MIN_RADAR_RANGE, maxAPIRange))
+	}
+	if d.Range < MIN_RADAR_RANGE || d.Range > d.MaxRange {
+		check(fmt.Errorf("defaults range must be between %d and max_range (%d) NM", MIN_RADAR_RANGE, d.MaxRange))
+	}
+	if d.AspectRatio <= 0 || d.AspectRatio > 2 {
+		check(fmt.Errorf("defaults aspect_ratio must be above 0 and at most 2"))
+	}
+	if d.TickInterval < 20*time.Millisecond || d.TickInterval > 5*time.Second {
+		check(fmt.Errorf("defaults tick_interval must be between 20ms and 5s"))
+	}
+
+	for name, t := range c.Themes {
+		check(t.validate(name))
+	}
+	_, custom := c.Themes[d.Theme]
+	if _, builtin := builtinThemes[d.Theme]; !custom && !builtin {
+		check(fmt.Errorf("defaults theme %q isn't a built in theme or one in [themes]", d.Theme))
+	}
+	if _, err := parseRadarRenderer(d.Renderer); err != nil {
+		check(fmt.Errorf("defaults renderer: %w", err))
+	}
+
+	check(c.Auth.validate(d.MaxRange))
+	check(c.Limits.validate())
+	return errors.Join(errs...)
+}
+
+// reloadConfig re-reads the config file, environment and flags, applying everything but
+// the server and data settings, which keep their startup values until a restart
+func reloadConfig(args []string) {
+	c, path, err := loadConfig("serve", args)
+	if err == nil {
+		err = c.validate()
+	}
+	if err != nil {
+		log.Printf("Not reloading config %s: %v", path, err)
+		return
+	}
+
+	old := settings()
+	if c.Server != old.Server || c.Data != old.Data {
+		log.Print("Server and data settings have changed, restart to apply them")
+	}
+	c.Server = old.Server
+	c.Data = old.Data
+	currentConfig.Store(c)
+	log.Printf("Reloaded config %s", path)
+}
+
+// runConfig implements "config check", validating the config file and overrides without
+// starting the server
+func runConfig(args []string) {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprintln(os.Stderr, "usage: whatplaneisthat config check [--config FILE] [flags]")
+		os.Exit(2)
+	}
+
+	c, path, err := loadConfig("config check", args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err == nil {
+		err = c.validate()
+	}
+	if path == "" {
+		path = "defaults"
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: invalid configuration:\n%v\n", path, err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s: configuration OK\n", path)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApplyConfigEnv(t *testing.T) {
+	tests := []struct {
+		env   []string
+		check func(c *config) bool
+		err   bool
+	}{
+		{env: []string{"WPIT_SERVER_PORT=2222"}, check: func(c *config) bool { return c.Server.Port == "2222" }},
+		{env: []string{"WPIT_DEFAULTS_LAT=51.47", "WPIT_DEFAULTS_LON=-0.4543"}, check: func(c *config) bool {
+			return c.Defaults.Lat == 51.47 && c.Defaults.Lon == -0.4543
+		}},
+		{env: []string{"WPIT_DEFAULTS_RANGE=40"}, check: func(c *config) bool { return c.Defaults.Range == 40 }},
+		{env: []string{"WPIT_SOURCES_POLL_INTERVAL=30s"}, check: func(c *config) bool { return c.Sources.PollInterval == 30*time.Second }},
+		{env: []string{"WPIT_SERVER_SESSION_EXPORT=true"}, check: func(c *config) bool { return c.Server.SessionExport }},
+		{env: []string{"WPIT_LIMITS_MAX_STREAMS=0"}, check: func(c *config) bool { return c.Limits.MaxStreams == 0 }},
+		// Values can contain =, and empty values still apply
+		{env: []string{"WPIT_SOURCES_ADSB_URL=http://localhost/point?key=a=b"}, check: func(c *config) bool {
+			return c.Sources.ADSBURL == "http://localhost/point?key=a=b"
+		}},
+		{env: []string{"WPIT_SERVER_HISTORY="}, check: func(c *config) bool { return c.Server.History == "" }},
+		// Anything else is left alone
+		{env: []string{"PORT=2222", "WPIT_SERVER_NOPE=1", "WPIT_SERVER_PORT"}, check: func(c *config) bool {
+			d := defaultConfig()
+			return c.Server == d.Server && c.Defaults == d.Defaults && c.Sources == d.Sources && c.Limits == d.Limits
+		}},
+
+		{env: []string{"WPIT_DEFAULTS_RANGE=far"}, err: true},
+		{env: []string{"WPIT_DEFAULTS_LAT=north"}, err: true},
+		{env: []string{"WPIT_SOURCES_POLL_INTERVAL=10"}, err: true},
+		{env: []string{"WPIT_SERVER_SESSION_EXPORT=maybe"}, err: true},
+	}
+	for _, tt := range tests {
+		c := defaultConfig()
+		err := applyConfigEnv(c, tt.env)
+		if tt.err {
+			if err == nil {
+				t.Errorf("applyConfigEnv(%q) succeeded, want an error", tt.env)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("applyConfigEnv(%q) failed: %v", tt.env, err)
+		} else if !tt.check(c) {
+			t.Errorf("applyConfigEnv(%q) didn't set the config as expected", tt.env)
+		}
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *config)
+		err    string
+	}{
+		{name: "defaults", change: func(c *config) {}},
+		{name: "several host key types", change: func(c *config) { c.Server.HostKeyTypes = "ed25519, RSA,ecdsa" }},
+		{name: "zero limits", change: func(c *config) { c.Limits = limitConfig{} }},
+
+		{name: "port not a number", change: func(c *config) { c.Server.Port = "ssh" }, err: "server port"},
+		{name: "port out of range", change: func(c *config) { c.Server.Port = "65536" }, err: "server port"},
+		{name: "host key type", change: func(c *config) { c.Server.HostKeyTypes = "ed25519,dsa" }, err: `unknown host key type "dsa"`},
+		{name: "source URL", change: func(c *config) { c.Sources.ADSBURL = "ftp://example.com" }, err: "sources adsb_url"},
+		{name: "poll interval", change: func(c *config) { c.Sources.PollInterval = 500 * time.Millisecond }, err: "poll_interval"},
+		{name: "lat", change: func(c *config) { c.Defaults.Lat = 91 }, err: "defaults lat"},
+		{name: "range over max_range", change: func(c *config) { c.Defaults.Range = 300 }, err: "defaults range"},
+		{name: "aspect ratio", change: func(c *config) { c.Defaults.AspectRatio = 0 }, err: "aspect_ratio"},
+		{name: "tick interval", change: func(c *config) { c.Defaults.TickInterval = time.Millisecond }, err: "tick_interval"},
+		{name: "theme", change: func(c *config) { c.Defaults.Theme = "nope" }, err: `theme "nope"`},
+		{name: "negative session limit", change: func(c *config) { c.Limits.MaxSessionsPerIP = -1 }, err: "limits can't be negative"},
+		{name: "negative stream limit", change: func(c *config) { c.Limits.MaxFeedLocations = -1 }, err: "limits can't be negative"},
+	}
+	for _, tt := range tests {
+		c := defaultConfig()
+		tt.change(c)
+		err := c.validate()
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%s: validate() failed: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: validate() = %v, want an error containing %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestConfigValidateReportsEveryError(t *testing.T) {
+	c := defaultConfig()
+	c.Server.Port = "0"
+	c.Defaults.Lon = 200
+	err := c.validate()
+	if err == nil || !strings.Contains(err.Error(), "server port") || !strings.Contains(err.Error(), "defaults lon") {
+		t.Errorf("validate() = %v, want both the port and lon errors", err)
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	file := "[server]\nport = \"2200\"\n\n[defaults]\nrange = 20\nlat = 51.47\n"
+	if err := os.WriteFile(path, []byte(file), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// The file overrides the defaults, the environment the file, and flags everything
+	t.Setenv("WPIT_DEFAULTS_RANGE", "30")
+	t.Setenv("WPIT_SERVER_PORT", "2201")
+
+	c, got, err := loadConfig("serve", []string{"--config", path, "--port", "2202"})
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	if got != path {
+		t.Errorf("loadConfig path %q, want %q", got, path)
+	}
+	if c.Defaults.Lat != 51.47 {
+		t.Errorf("lat %v, want 51.47 from the file", c.Defaults.Lat)
+	}
+	if c.Defaults.Range != 30 {
+		t.Errorf("range %d, want 30 from the environment", c.Defaults.Range)
+	}
+	if c.Server.Port != "2202" {
+		t.Errorf("port %s, want 2202 from the flags", c.Server.Port)
+	}
+	if c.Defaults.Lon != defaultConfig().Defaults.Lon {
+		t.Errorf("lon %v, want the default", c.Defaults.Lon)
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	geohashAlphabet   = "0123456789bcdefghjkmnpqrstuvwxyz"
+	plusCodeAlphabet  = "23456789CFGHJMPQRVWX"
+	plusCodeSeparator = '+'
+	plusCodePairs     = 5
+	plusCodeGridRows  = 5
+	plusCodeGridCols  = 4
+)
+
+var maidenheadPattern = regexp.MustCompile(`^[A-Ra-r]{2}([0-9]{2}([A-Xa-x]{2}([0-9]{2})?)?)?$`)
+
+// dmsSeparators are replaced with spaces before splitting degrees, minutes and seconds
+var dmsSeparators = strings.NewReplacer("°", " ", "º", " ", "'", " ", "′", " ", "\"", " ", "″", " ", ":", " ", "D", " ", "M", " ", "S", " ")
+
+// dmsSecondsMarker matches the S ending the seconds of 53D47M43S, which isn't a hemisphere
+var dmsSecondsMarker = regexp.MustCompile(`M\s*[0-9.]+S$`)
+
+// parseCoordinateAxis parses a latitude or longitude in decimal degrees or degrees, minutes
+// and seconds with an optional N/S/E/W hemisphere, e.g. 53°47'43"N, N53 47.72 or -1.66134
+func parseCoordinateAxis(s string, isLat bool) (float64, error) {
+	axis, limit, positive, negative := "longitude", 180.0, "E", "W"
+	if isLat {
+		axis, limit, positive, negative = "latitude", 90.0, "N", "S"
+	}
+
+	raw := strings.TrimSpace(s)
+	v := strings.ToUpper(raw)
+	if v == "" {
+		return 0, fmt.Errorf("%s is empty", axis)
+	}
+	if dmsSecondsMarker.MatchString(v) {
+		v = strings.TrimSuffix(v, "S")
+	}
+
+	sign := 1.0
+	hasHemisphere := false
+	for _, hemisphere := range []string{positive, negative, "N", "S", "E", "W"} {
+		trimmed := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(v, hemisphere), hemisphere))
+		if trimmed == v {
+			continue
+		}
+		if hemisphere != positive && hemisphere != negative {
+			return 0, fmt.Errorf("%s can't be in hemisphere %s", axis, hemisphere)
+		}
+		if hemisphere == negative {
+			sign = -1
+		}
+		hasHemisphere = true
+		v = trimmed
+		break
+	}
+
+	fields := strings.Fields(dmsSeparators.Replace(v))
+	if len(fields) == 0 || len(fields) > 3 {
+		return 0, fmt.Errorf("invalid %s %q", axis, raw)
+	}
+
+	var value float64
+	for i, field := range fields {
+		n, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s %q", axis, raw)
+		}
+		// Signbit rather than n < 0 so -0°27' is west of Greenwich
+		if i > 0 && (math.Signbit(n) || n >= 60) {
+			return 0, fmt.Errorf("minutes and seconds in %q must be between 0 and 60", raw)
+		}
+		if i == 0 && math.Signbit(n) {
+			if hasHemisphere {
+				return 0, fmt.Errorf("%q has both a sign and a hemisphere", raw)
+			}
+			sign = -1
+			n = -n
+		}
+		value += n / math.Pow(60, float64(i))
+	}
+	value *= sign
+
+	if value < -limit || value > limit {
+		return 0, fmt.Errorf("%s must be between -%g and %g", axis, limit, limit)
+	}
+	return value, nil
+}
+
+// parseMaidenhead returns the centre of a 2, 4, 6 or 8 character Maidenhead grid locator
+func parseMaidenhead(s string) (float64, float64, error) {
+	if !maidenheadPattern.MatchString(s) {
+		return 0, 0, fmt.Errorf("invalid grid locator %q", s)
+	}
+	s = strings.ToUpper(s)
+
+	lon, lat := -180.0, -90.0
+	lonSize, latSize := 20.0, 10.0
+	lon += float64(s[0]-'A') * lonSize
+	lat += float64(s[1]-'A') * latSize
+
+	if len(s) >= 4 {
+		lonSize, latSize = lonSize/10, latSize/10
+		lon += float64(s[2]-'0') * lonSize
+		lat += float64(s[3]-'0') * latSize
+	}
+	if len(s) >= 6 {
+		lonSize, latSize = lonSize/24, latSize/24
+		lon += float64(s[4]-'A') * lonSize
+		lat += float64(s[5]-'A') * latSize
+	}
+	if len(s) == 8 {
+		lonSize, latSize = lonSize/10, latSize/10
+		lon += float64(s[6]-'0') * lonSize
+		lat += float64(s[7]-'0') * latSize
+	}
+	return lat + latSize/2, lon + lonSize/2, nil
+}
+
+// parseGeohash returns the centre of a geohash cell
+func parseGeohash(s string) (float64, float64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" || len(s) > 12 {
+		return 0, 0, fmt.Errorf("invalid geohash %q", s)
+	}
+
+	latRange := [2]float64{-90, 90}
+	lonRange := [2]float64{-180, 180}
+	even := true
+	for _, c := range s {
+		idx := strings.IndexRune(geohashAlphabet, c)
+		if idx < 0 {
+			return 0, 0, fmt.Errorf("invalid geohash %q", s)
+		}
+		for bit := 4; bit >= 0; bit-- {
+			r := &latRange
+			if even {
+				r = &lonRange
+			}
+			mid := (r[0] + r[1]) / 2
+			if idx&(1<<bit) != 0 {
+				r[0] = mid
+			} else {
+				r[1] = mid
+			}
+			even = !even
+		}
+	}
+	return (latRange[0] + latRange[1]) / 2, (lonRange[0] + lonRange[1]) / 2, nil
+}
+
+func plusCodeDigit(c byte) (int, bool) {
+	idx := strings.IndexByte(plusCodeAlphabet, c)
+	return idx, idx >= 0
+}
+
+// decodePlusCode returns the centre of a full Open Location Code
+func decodePlusCode(code string) (float64, float64, error) {
+	digits := strings.ReplaceAll(code, string(plusCodeSeparator), "")
+	digits = strings.TrimRight(digits, "0")
+	if len(digits) < 2 || len(digits)%2 == 1 && len(digits) < 2*plusCodePairs {
+		return 0, 0, fmt.Errorf("invalid plus code %q", code)
+	}
+
+	lat, lon := 0.0, 0.0
+	latRes, lonRes := 400.0, 400.0
+	for i := 0; i < len(digits) && i < 2*plusCodePairs; i += 2 {
+		latDigit, ok1 := plusCodeDigit(digits[i])
+		lonDigit, ok2 := plusCodeDigit(digits[i+1])
+		if !ok1 || !ok2 {
+			return 0, 0, fmt.Errorf("invalid plus code %q", code)
+		}
+		latRes, lonRes = latRes/20, lonRes/20
+		lat += float64(latDigit) * latRes
+		lon += float64(lonDigit) * lonRes
+	}
+	for i := 2 * plusCodePairs; i < len(digits); i++ {
+		d, ok := plusCodeDigit(digits[i])
+		if !ok {
+			return 0, 0, fmt.Errorf("invalid plus code %q", code)
+		}
+		latRes, lonRes = latRes/plusCodeGridRows, lonRes/plusCodeGridCols
+		lat += float64(d/plusCodeGridCols) * latRes
+		lon += float64(d%plusCodeGridCols) * lonRes
+	}
+
+	return math.Min(lat-90+latRes/2, 90), lon - 180 + lonRes/2, nil
+}
+
+// encodePlusCodePrefix returns the first n digits of the plus code for lat, lon
+func encodePlusCodePrefix(lat float64, lon float64, n int) string {
+	lat = math.Min(math.Max(lat, -90), 90-1e-9) + 90
+	lon = math.Mod(lon+180+360, 360)
+
+	var b strings.Builder
+	res := 20.0
+	for i := 0; i < n/2; i++ {
+		latDigit := int(lat / res)
+		lonDigit := int(lon / res)
+		b.WriteByte(plusCodeAlphabet[latDigit])
+		b.WriteByte(plusCodeAlphabet[lonDigit])
+		lat -= float64(latDigit) * res
+		lon -= float64(lonDigit) * res
+		res /= 20
+	}
+	return b.String()
+}
+
+// parsePlusCode parses a full plus code, or a short code recovered relative to refLat, refLon
+func parsePlusCode(s string, refLat float64, refLon float64) (float64, float64, error) {
+	code := strings.ToUpper(strings.TrimSpace(s))
+	sep := strings.IndexByte(code, plusCodeSeparator)
+	if sep < 0 || sep > 8 || sep%2 == 1 {
+		return 0, 0, fmt.Errorf("invalid plus code %q", s)
+	}
+	if sep == 8 {
+		return decodePlusCode(code)
+	}
+
+	// Short codes drop leading digits, which are taken from the reference location
+	paddingLength := 8 - sep
+	resolution := math.Pow(20, 2-float64(paddingLength)/2)
+	halfRes := resolution / 2
+
+	lat, lon, err := decodePlusCode(encodePlusCodePrefix(refLat, refLon, paddingLength) + code)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid plus code %q", s)
+	}
+	switch {
+	case refLat+halfRes < lat && lat-resolution >= -90:
+		lat -= resolution
+	case refLat-halfRes > lat && lat+resolution <= 90:
+		lat += resolution
+	}
+	switch {
+	case refLon+halfRes < lon:
+		lon -= resolution
+	case refLon-halfRes > lon:
+		lon += resolution
+	}
+	return lat, lon, nil
+}
+
+func isGeohash(s string) bool {
+	if s == "" || len(s) > 12 {
+		return false
+	}
+	for _, c := range strings.ToLower(s) {
+		if !strings.ContainsRune(geohashAlphabet, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// parsePosition parses a string describing a full position rather than a single axis: a
+// plus code (short codes may be followed by a town to resolve them against), a Maidenhead
+// grid locator, a geohash or a "lat, lon" pair. ok is false when s is just a single axis.
+func parsePosition(s string, refLat float64, refLon float64) (lat float64, lon float64, ok bool, err error) {
+	s = strings.TrimSpace(s)
+
+	if strings.ContainsRune(s, plusCodeSeparator) {
+		code, locality, _ := strings.Cut(s, " ")
+		if locality = strings.TrimSpace(locality); locality != "" {
+			matches := gazetteer.Search(locality)
+			if len(matches) == 0 {
+				return 0, 0, true, fmt.Errorf("unknown place %q", locality)
+			}
+			refLat, refLon = matches[0].lat, matches[0].lon
+		}
+		lat, lon, err = parsePlusCode(code, refLat, refLon)
+		return lat, lon, true, err
+	}
+
+	if latStr, lonStr, found := strings.Cut(s, ","); found {
+		if lat, err = parseCoordinateAxis(latStr, true); err != nil {
+			return 0, 0, true, err
+		}
+		lon, err = parseCoordinateAxis(lonStr, false)
+		return lat, lon, true, err
+	}
+
+	// Maidenhead locators are conventionally written with an upper case field, geohashes in
+	// lower case, which is the only way to tell apart the few strings valid as both
+	if maidenheadPattern.MatchString(s) && (!isGeohash(s) || s[:2] == strings.ToUpper(s[:2])) {
+		lat, lon, err = parseMaidenhead(s)
+		return lat, lon, true, err
+	}
+	// A number with a hemisphere such as 53n is also a valid geohash, but is far more likely
+	// to be meant as a single axis
+	if _, err := parseCoordinateAxis(s, true); err == nil {
+		return 0, 0, false, nil
+	}
+	if _, err := parseCoordinateAxis(s, false); err == nil {
+		return 0, 0, false, nil
+	}
+	if isGeohash(s) && len(s) >= 2 && strings.IndexFunc(s, func(r rune) bool { return r >= 'a' && r <= 'z' }) >= 0 {
+		lat, lon, err = parseGeohash(s)
+		return lat, lon, true, err
+	}
+	return 0, 0, false, nil
+}
+
+// parseLocation parses either a full position or a latitude and longitude given separately.
+// Empty values keep the reference location.
+func parseLocation(latStr string, lonStr string, refLat float64, refLon float64) (float64, float64, error) {
+	if lat, lon, ok, err := parsePosition(latStr, refLat, refLon); ok {
+		return lat, lon, err
+	}
+
+	lat, lon := refLat, refLon
+	var err error
+	if strings.TrimSpace(latStr) != "" {
+		if lat, err = parseCoordinateAxis(latStr, true); err != nil {
+			return 0, 0, err
+		}
+	}
+	if strings.TrimSpace(lonStr) != "" {
+		if lon, err = parseCoordinateAxis(lonStr, false); err != nil {
+			return 0, 0, err
+		}
+	}
+	return lat, lon, nil
+}
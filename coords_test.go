@@ -0,0 +1,122 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseCoordinateAxis(t *testing.T) {
+	tests := []struct {
+		in    string
+		isLat bool
+		want  float64
+		err   bool
+	}{
+		{in: `53°47'43"N`, isLat: true, want: 53.795278},
+		{in: `53°47′43″S`, isLat: true, want: -53.795278},
+		{in: "53D47M43S", isLat: true, want: 53.795278},
+		{in: "53D47M43SS", isLat: true, want: -53.795278},
+		{in: "S53D47M43S", isLat: true, want: -53.795278},
+		{in: "53:47:43", isLat: true, want: 53.795278},
+		{in: "N53 47.72", isLat: true, want: 53.795333},
+		{in: `001°39'41"W`, want: -1.661389},
+		{in: "1D39M41SW", want: -1.661389},
+		{in: "1D39M41S", want: 1.661389},
+		{in: "53.79538N", isLat: true, want: 53.79538},
+		{in: "53.79538 s", isLat: true, want: -53.79538},
+		{in: "53n", isLat: true, want: 53},
+		{in: "1.66134W", want: -1.66134},
+		{in: "E1.66134", want: 1.66134},
+		{in: "-1.66134", want: -1.66134},
+		{in: "-0°27'", want: -0.45},
+		{in: "-0 27 0", want: -0.45},
+		{in: "-0:07:39", want: -0.1275},
+		{in: "-0.5", isLat: true, want: -0.5},
+		{in: "0°27'W", want: -0.45},
+
+		{in: "", isLat: true, err: true},
+		{in: "abc", isLat: true, err: true},
+		{in: "91N", isLat: true, err: true},
+		{in: "181E", err: true},
+		{in: "1W", isLat: true, err: true},
+		{in: "53N", err: true},
+		{in: "-53N", isLat: true, err: true},
+		{in: "53 61", isLat: true, err: true},
+		{in: "53 -0 30", isLat: true, err: true},
+		{in: "-0 27N", isLat: true, err: true},
+		{in: "53 1 2 3", isLat: true, err: true},
+	}
+	for _, tt := range tests {
+		got, err := parseCoordinateAxis(tt.in, tt.isLat)
+		if tt.err {
+			if err == nil {
+				t.Errorf("parseCoordinateAxis(%q, %v) = %v, want an error", tt.in, tt.isLat, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseCoordinateAxis(%q, %v) failed: %v", tt.in, tt.isLat, err)
+		} else if math.Abs(got-tt.want) > 1e-5 {
+			t.Errorf("parseCoordinateAxis(%q, %v) = %v, want %v", tt.in, tt.isLat, got, tt.want)
+		}
+	}
+}
+
+func TestParsePosition(t *testing.T) {
+	// Short plus codes without a town are recovered relative to central Zurich
+	refLat, refLon := 47.37, 8.54
+	tests := []struct {
+		in       string
+		wantLat  float64
+		wantLon  float64
+		notFound bool
+		err      bool
+	}{
+		{in: "53.795, -1.661", wantLat: 53.795, wantLon: -1.661},
+		{in: `53°47'43"N, 1°39'41"W`, wantLat: 53.795278, wantLon: -1.661389},
+		{in: "51 28 12, -0 27 0", wantLat: 51.47, wantLon: -0.45},
+		{in: "IO93fs", wantLat: 53.770833, wantLon: -1.541667},
+		{in: "IO93", wantLat: 53.5, wantLon: -1},
+		{in: "u4pruydqqvj", wantLat: 57.649110, wantLon: 10.407439},
+		{in: "8FVC9G8F+6X", wantLat: 47.365563, wantLon: 8.524938},
+		{in: "9G8F+6X", wantLat: 47.365563, wantLon: 8.524938},
+		{in: "9G8F+6X Zurich", wantLat: 47.365563, wantLon: 8.524938},
+
+		{in: "53n", notFound: true},
+		{in: "1.5w", notFound: true},
+		{in: "53D47M43S", notFound: true},
+		{in: "", notFound: true},
+		{in: "9G8F+6X Nowhereville", err: true},
+		{in: "8FVC9G8+6X", err: true},
+		{in: "91, 0", err: true},
+		{in: "53, 181", err: true},
+	}
+	for _, tt := range tests {
+		lat, lon, ok, err := parsePosition(tt.in, refLat, refLon)
+		switch {
+		case tt.notFound:
+			if ok {
+				t.Errorf("parsePosition(%q) = %v, %v, want a single axis", tt.in, lat, lon)
+			}
+		case tt.err:
+			if !ok || err == nil {
+				t.Errorf("parsePosition(%q) = %v, %v, %v, want an error", tt.in, lat, lon, ok)
+			}
+		case !ok || err != nil:
+			t.Errorf("parsePosition(%q) failed: ok %v, %v", tt.in, ok, err)
+		case math.Abs(lat-tt.wantLat) > 1e-5 || math.Abs(lon-tt.wantLon) > 1e-5:
+			t.Errorf("parsePosition(%q) = %v, %v, want %v, %v", tt.in, lat, lon, tt.wantLat, tt.wantLon)
+		}
+	}
+}
+
+func TestParseLocation(t *testing.T) {
+	lat, lon, err := parseLocation("53n", "1.5w", 0, 0)
+	if err != nil || lat != 53 || lon != -1.5 {
+		t.Errorf("parseLocation(53n, 1.5w) = %v, %v, %v, want 53, -1.5", lat, lon, err)
+	}
+	lat, lon, err = parseLocation("", "", 51.47, -0.45)
+	if err != nil || lat != 51.47 || lon != -0.45 {
+		t.Errorf("parseLocation with no values = %v, %v, %v, want the reference location", lat, lon, err)
+	}
+}
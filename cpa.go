@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	// overheadThresholdNM is the closest approach distance that counts as flying overhead
+	overheadThresholdNM = 5.0
+	// cpaLookahead is how far ahead the closest approach is predicted
+	cpaLookahead = 30 * time.Minute
+)
+
+// setClosestApproach predicts when and how close p will pass the observer, assuming it holds
+// its current track and ground speed. Requires the distance and bearing to already be set.
+func setClosestApproach(p *plane, now time.Time) {
+	p.Approaching = false
+	p.CPADistance = p.DistanceFromObserver
+	p.CPATime = now
+
+	if p.GroundSpeed <= 0 {
+		return
+	}
+
+	// Flat earth approximation centred on the observer, x east and y north in NM
+	x := p.DistanceFromObserver * math.Sin(p.BearingFromObserver)
+	y := p.DistanceFromObserver * math.Cos(p.BearingFromObserver)
+	track := p.Track * math.Pi / 180
+	vx := p.GroundSpeed * math.Sin(track)
+	vy := p.GroundSpeed * math.Cos(track)
+
+	positionAt := p.positionTime(now)
+	tHours := -(x*vx + y*vy) / (vx*vx + vy*vy)
+	cpaTime := positionAt.Add(time.Duration(tHours * float64(time.Hour)))
+	if tHours <= 0 || cpaTime.Before(now) || cpaTime.Sub(now) > cpaLookahead {
+		return
+	}
+
+	p.Approaching = true
+	p.CPADistance = math.Hypot(x+vx*tHours, y+vy*tHours)
+	p.CPATime = cpaTime
+}
+
+// formatCountdown formats a duration as 2m10s, dropping the minutes when under a minute
+func formatCountdown(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d < 0 {
+		d = 0
+	}
+	minutes := int(d.Minutes())
+	seconds := int(d.Seconds()) % 60
+	if minutes == 0 {
+		return fmt.Sprintf("%ds", seconds)
+	}
+	return fmt.Sprintf("%dm%02ds", minutes, seconds)
+}
+
+// formatCPA is the table cell for a plane's closest approach
+func formatCPA(p plane, now time.Time) string {
+	if !p.Approaching || p.CPATime.Before(now) {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f %s", p.CPADistance, formatCountdown(p.CPATime.Sub(now)))
+}
+
+// nextOverhead returns the plane that will next pass within overheadThresholdNM
+func (m *model) nextOverhead(now time.Time) (plane, bool) {
+	var next plane
+	found := false
+	for _, p := range m.planes {
+		if !p.Approaching || p.CPADistance > overheadThresholdNM || p.CPATime.Before(now) {
+			continue
+		}
+		if !found || p.CPATime.Before(next.CPATime) {
+			next = p
+			found = true
+		}
+	}
+	return next, found
+}
+
+func (m *model) nextOverheadStatus(now time.Time) string {
+	p, ok := m.nextOverhead(now)
+	if !ok {
+		return "next overhead: none"
+	}
+	name := strings.TrimSpace(p.FlightCode)
+	if name == "" {
+		name = p.Hex
+	}
+	return fmt.Sprintf("next overhead: %s in %s, %.1f NM", name, formatCountdown(p.CPATime.Sub(now)), p.CPADistance)
+}
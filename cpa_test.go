@@ -0,0 +1,100 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSetClosestApproach(t *testing.T) {
+	now := time.Date(2025, 7, 1, 14, 32, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		p            plane
+		approaching  bool
+		wantDistance float64
+		wantIn       time.Duration
+	}{
+		{
+			name:         "heading straight for the observer",
+			p:            plane{DistanceFromObserver: 10, BearingFromObserver: 0, Track: 180, GroundSpeed: 600},
+			approaching:  true,
+			wantDistance: 0,
+			wantIn:       time.Minute,
+		},
+		{
+			name:         "passing to one side",
+			p:            plane{DistanceFromObserver: 10, BearingFromObserver: 0, Track: 225, GroundSpeed: 360},
+			approaching:  true,
+			wantDistance: 5 * math.Sqrt2,
+			wantIn:       70710678 * time.Microsecond, // 5√2 NM at 360 kn
+		},
+		{
+			name:         "from the southwest",
+			p:            plane{DistanceFromObserver: 20, BearingFromObserver: 225 * math.Pi / 180, Track: 45, GroundSpeed: 480},
+			approaching:  true,
+			wantDistance: 0,
+			wantIn:       150 * time.Second,
+		},
+		{
+			name:         "position reported 30s before the poll",
+			p:            plane{DistanceFromObserver: 10, BearingFromObserver: 0, Track: 180, GroundSpeed: 600, PolledAt: now, SeenPos: 30},
+			approaching:  true,
+			wantDistance: 0,
+			wantIn:       30 * time.Second,
+		},
+		{
+			name:         "crossing at its closest now",
+			p:            plane{DistanceFromObserver: 10, BearingFromObserver: 0, Track: 90, GroundSpeed: 600},
+			wantDistance: 10,
+		},
+		{
+			name:         "flying away",
+			p:            plane{DistanceFromObserver: 10, BearingFromObserver: 0, Track: 0, GroundSpeed: 600},
+			wantDistance: 10,
+		},
+		{
+			name:         "not moving",
+			p:            plane{DistanceFromObserver: 3, BearingFromObserver: 1, Track: 180},
+			wantDistance: 3,
+		},
+		{
+			name:         "beyond the lookahead",
+			p:            plane{DistanceFromObserver: 10, BearingFromObserver: 0, Track: 180, GroundSpeed: 10},
+			wantDistance: 10,
+		},
+	}
+	for _, tt := range tests {
+		p := tt.p
+		setClosestApproach(&p, now)
+		if p.Approaching != tt.approaching {
+			t.Errorf("%s: approaching %v, want %v", tt.name, p.Approaching, tt.approaching)
+		}
+		if math.Abs(p.CPADistance-tt.wantDistance) > 1e-6 {
+			t.Errorf("%s: closest approach %.4f NM, want %.4f", tt.name, p.CPADistance, tt.wantDistance)
+		}
+		if in := p.CPATime.Sub(now); (in - tt.wantIn).Abs() > time.Millisecond {
+			t.Errorf("%s: closest approach in %v, want %v", tt.name, in, tt.wantIn)
+		}
+	}
+}
+
+func TestFormatCountdown(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0s"},
+		{-5 * time.Second, "0s"},
+		{1500 * time.Millisecond, "2s"},
+		{59 * time.Second, "59s"},
+		{60 * time.Second, "1m00s"},
+		{130 * time.Second, "2m10s"},
+		{31 * time.Minute, "31m00s"},
+	}
+	for _, tt := range tests {
+		if got := formatCountdown(tt.d); got != tt.want {
+			t.Errorf("formatCountdown(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// datasetKind is a reference dataset that can be loaded with "whatplaneisthat import"
+type datasetKind struct {
+	description string
+	// count parses the dataset, returning how many usable records it has
+	count func(r io.Reader) (int, error)
+}
+
+var datasetKinds = map[string]datasetKind{
+	"airports": {
+		description: "OurAirports airports.csv, searched from the location modal",
+		count:       countEntries(parseAirports),
+	},
+	"places": {
+		description: "towns and cities CSV with name, country, latitude, longitude and population columns",
+		count:       countEntries(parsePlaces),
+	},
+	"airlines": {
+		description: "CSV with icao and name columns, naming airlines the route lookup doesn't know",
+		count:       countEntries(parseAirlines),
+	},
+	"aircraft": {
+		description: "CSV with type and noise_class columns, classifying aircraft types for noise estimates",
+		count:       countEntries(parseAircraftTypes),
+	},
+}
+
+func countEntries[T []gazetteerEntry | map[string]string | map[string]noiseClass](parse func(io.Reader) (T, error)) func(io.Reader) (int, error) {
+	return func(r io.Reader) (int, error) {
+		entries, err := parse(r)
+		return len(entries), err
+	}
+}
+
+// airlinesPath and aircraftPath are the airline and aircraft type datasets, empty when
+// none have been imported
+var (
+	airlinesPath string
+	aircraftPath string
+)
+
+func importedDatasetPath(dir string, kind string) string {
+	return filepath.Join(dir, kind+".csv")
+}
+
+// path is the file the dataset of a kind is read from: the configured file, else an
+// imported one, else empty for the bundled data
+func (d dataConfig) path(kind string) string {
+	configured := map[string]string{
+		"airports": d.Airports,
+		"places":   d.Places,
+		"airlines": d.Airlines,
+		"aircraft": d.Aircraft,
+	}[kind]
+	if configured != "" {
+		return configured
+	}
+	if d.Dir != "" {
+		path := importedDatasetPath(d.Dir, kind)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// useDataConfig points the dataset loaders at the configured or imported files
+func useDataConfig(d dataConfig) {
+	airportsPath = d.path("airports")
+	placesPath = d.path("places")
+	airlinesPath = d.path("airlines")
+	aircraftPath = d.path("aircraft")
+}
+
+// parseAirlines reads airline names keyed by their ICAO code, the prefix of their callsigns
+func parseAirlines(r io.Reader) (map[string]string, error) {
+	rows, err := readCSV(r)
+	if err != nil {
+		return nil, err
+	}
+
+	airlines := make(map[string]string)
+	for _, row := range rows {
+		icao := strings.ToUpper(strings.TrimSpace(row["icao"]))
+		name := strings.TrimSpace(row["name"])
+		if len(icao) != 3 || name == "" {
+			continue
+		}
+		airlines[icao] = name
+	}
+	return airlines, nil
+}
+
+func parseNoiseClass(s string) (noiseClass, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for class, name := range noiseClassNames {
+		if s == name || s == strings.Fields(name)[0] {
+			return class, true
+		}
+	}
+	return 0, false
+}
+
+// parseAircraftTypes reads noise classes keyed by ICAO type designator
+func parseAircraftTypes(r io.Reader) (map[string]noiseClass, error) {
+	rows, err := readCSV(r)
+	if err != nil {
+		return nil, err
+	}
+
+	types := make(map[string]noiseClass)
+	for _, row := range rows {
+		designator := strings.ToUpper(strings.TrimSpace(row["type"]))
+		class, ok := parseNoiseClass(row["noise_class"])
+		if designator == "" || !ok {
+			continue
+		}
+		types[designator] = class
+	}
+	return types, nil
+}
+
+func parseFile[T any](path string, parse func(io.Reader) (T, error)) (T, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	defer f.Close()
+	return parse(f)
+}
+
+// referenceData holds the airline and aircraft type datasets, loaded on first use
+type referenceData struct {
+	once     sync.Once
+	airlines map[string]string
+	aircraft map[string]noiseClass
+}
+
+var reference = &referenceData{}
+
+func (d *referenceData) load() {
+	d.once.Do(func() {
+		var err error
+		if airlinesPath != "" {
+			if d.airlines, err = parseFile(airlinesPath, parseAirlines); err != nil {
+				log.Printf("Could not load airlines: %v", err)
+			}
+		}
+		if aircraftPath != "" {
+			if d.aircraft, err = parseFile(aircraftPath, parseAircraftTypes); err != nil {
+				log.Printf("Could not load aircraft types: %v", err)
+			}
+		}
+	})
+}
+
+// airline names the airline flying callsign from its three letter ICAO prefix
+func (d *referenceData) airline(callsign string) string {
+	d.load()
+	callsign = strings.ToUpper(strings.TrimSpace(callsign))
+	if len(callsign) <= 3 || strings.IndexFunc(callsign[:3], func(r rune) bool { return !unicode.IsLetter(r) }) != -1 {
+		return ""
+	}
+	return d.airlines[callsign[:3]]
+}
+
+func (d *referenceData) noiseClass(aircraftType string) (noiseClass, bool) {
+	d.load()
+	class, ok := d.aircraft[strings.ToUpper(strings.TrimSpace(aircraftType))]
+	return class, ok
+}
+
+func importUsage(fs *flag.FlagSet) func() {
+	return func() {
+		names := make([]string, 0, len(datasetKinds))
+		for name := range datasetKinds {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		out := fs.Output()
+		fmt.Fprintln(out, "usage: whatplaneisthat import [flags] DATASET FILE")
+		fmt.Fprintln(out, "\nDatasets:")
+		for _, name := range names {
+			fmt.Fprintf(out, "  %-10s %s\n", name, datasetKinds[name].description)
+		}
+		fmt.Fprintln(out, "\nFlags:")
+		fs.PrintDefaults()
+	}
+}
+
+// runImport checks a dataset and copies it into the data directory, where the server and
+// tui pick it up the next time they start
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	configPath := addConfigFlag(fs)
+	dir := fs.String("data-dir", "", "Directory to import into (default: the config's data dir)")
+	fs.Usage = importUsage(fs)
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	name, src := fs.Arg(0), fs.Arg(1)
+	kind, ok := datasetKinds[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown dataset %q\n", name)
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if *dir == "" {
+		c, err := readConfig(*configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+			os.Exit(1)
+		}
+		*dir = c.Data.Dir
+	}
+
+	data, err := os.ReadFile(src)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	n, err := kind.count(bytes.NewReader(data))
+	if err == nil && n == 0 {
+		err = fmt.Errorf("no usable records, expected %s", kind.description)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not import %s: %v\n", src, err)
+		os.Exit(1)
+	}
+
+	// Write a temporary file and rename it so a running server never reads half a dataset
+	dst := importedDatasetPath(*dir, name)
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	tmp := dst + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Imported %d %s into %s\n", n, name, dst)
+}
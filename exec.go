@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+// execAircraft is a plane as printed by the exec commands
+type execAircraft struct {
+	Hex          string   `json:"hex"`
+	Flight       string   `json:"flight"`
+	Airline      string   `json:"airline"`
+	Origin       string   `json:"origin"`
+	Destination  string   `json:"destination"`
+	Lat          float64  `json:"lat"`
+	Lon          float64  `json:"lon"`
+	AltitudeFt   *float64 `json:"altitude_ft"`
+	DistanceNM   float64  `json:"distance_nm"`
+	BearingDeg   float64  `json:"bearing_deg"`
+	ElevationDeg *float64 `json:"elevation_deg"`
+	NoiseDBA     *float64 `json:"noise_dba"`
+	CPANM        *float64 `json:"cpa_nm"`
+	CPAInSec     *float64 `json:"cpa_in_s"`
+}
+
+var execColumns = []string{"flight", "hex", "airline", "origin", "destination", "alt_ft", "dist_nm", "bearing", "elev_deg", "dba", "cpa_nm", "cpa_in_s"}
+
+// execCommand lists the planes an exec command prints, in the order it prints them
+type execCommand struct {
+	description string
+	selectFn    func(planes []plane, now time.Time) []plane
+}
+
+var execCommands = map[string]execCommand{
+	"nearby": {
+		description: "every aircraft in range, nearest first",
+		selectFn: func(planes []plane, now time.Time) []plane {
+			sort.SliceStable(planes, func(i, j int) bool {
+				return planes[i].DistanceFromObserver < planes[j].DistanceFromObserver
+			})
+			return planes
+		},
+	},
+	"overhead": {
+		description: fmt.Sprintf("aircraft within %.0f NM now or due to pass within it, soonest first", overheadThresholdNM),
+		selectFn: func(planes []plane, now time.Time) []plane {
+			var overhead []plane
+			for _, p := range planes {
+				if p.DistanceFromObserver <= overheadThresholdNM ||
+					p.Approaching && p.CPADistance <= overheadThresholdNM && p.CPATime.After(now) {
+					overhead = append(overhead, p)
+				}
+			}
+			sort.SliceStable(overhead, func(i, j int) bool {
+				return overheadETA(overhead[i], now) < overheadETA(overhead[j], now)
+			})
+			return overhead
+		},
+	},
+}
+
+// overheadETA is how long until p is closest to the observer, zero if it's already overhead
+func overheadETA(p plane, now time.Time) time.Duration {
+	if p.DistanceFromObserver <= overheadThresholdNM || !p.Approaching {
+		return 0
+	}
+	return p.CPATime.Sub(now)
+}
+
+func newExecAircraft(p plane, now time.Time) execAircraft {
+	round := func(v float64, places float64) *float64 {
+		scale := math.Pow(10, places)
+		r := math.Round(v*scale) / scale
+		return &r
+	}
+
+	a := execAircraft{
+		Hex:         p.Hex,
+		Flight:      strings.TrimSpace(p.FlightCode),
+		Airline:     p.RouteInfo.Airline,
+		Origin:      p.RouteInfo.OriginMunicipality,
+		Destination: p.RouteInfo.DestMunicipality,
+		Lat:         p.Lat,
+		Lon:         p.Lon,
+		DistanceNM:  *round(p.DistanceFromObserver, 2),
+		BearingDeg:  *round(math.Mod(p.BearingFromObserver*180/math.Pi+360, 360), 1),
+	}
+	if altitude, ok := p.Altitude(); ok {
+		a.AltitudeFt = &altitude
+	}
+	if p.HasAltitude {
+		a.ElevationDeg = round(p.ElevationAngle, 1)
+	}
+	if p.Audible {
+		a.NoiseDBA = round(p.NoiseLevel, 0)
+	}
+	if p.Approaching && p.CPATime.After(now) {
+		a.CPANM = round(p.CPADistance, 2)
+		a.CPAInSec = round(p.CPATime.Sub(now).Seconds(), 0)
+	}
+	return a
+}
+
+func formatOptional(v *float64, format string) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprintf(format, *v)
+}
+
+func (a execAircraft) record() []string {
+	return []string{
+		a.Flight,
+		a.Hex,
+		a.Airline,
+		a.Origin,
+		a.Destination,
+		formatOptional(a.AltitudeFt, "%.0f"),
+		strconv.FormatFloat(a.DistanceNM, 'f', 2, 64),
+		strconv.FormatFloat(a.BearingDeg, 'f', 0, 64),
+		formatOptional(a.ElevationDeg, "%.1f"),
+		formatOptional(a.NoiseDBA, "%.0f"),
+		formatOptional(a.CPANM, "%.2f"),
+		formatOptional(a.CPAInSec, "%.0f"),
+	}
+}
+
+func writeAircraftText(w io.Writer, aircraft []execAircraft) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(execColumns, "\t")))
+	for _, a := range aircraft {
+		record := a.record()
+		for i, v := range record {
+			if v == "" {
+				record[i] = "-"
+			}
+		}
+		fmt.Fprintln(tw, strings.Join(record, "\t"))
+	}
+	return tw.Flush()
+}
+
+func writeAircraftCSV(w io.Writer, aircraft []execAircraft) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(execColumns); err != nil {
+		return err
+	}
+	for _, a := range aircraft {
+		if err := cw.Write(a.record()); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeAircraftJSON(w io.Writer, m *model, aircraft []execAircraft, now time.Time) error {
+	if aircraft == nil {
+		aircraft = []execAircraft{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Time     time.Time      `json:"time"`
+		Observer streamObserver `json:"observer"`
+		Aircraft []execAircraft `json:"aircraft"`
+	}{now.UTC(), streamObserver{Lat: m.lat, Lon: m.lon, RangeNM: m.radarRange}, aircraft})
+}
+
+// formatFlags adds the output format flags shared by the exec commands and query, returning
+// a function that resolves them to text, json or csv once parsed
+func formatFlags(fs *flag.FlagSet) func() (string, error) {
+	format := fs.String("format", "text", "Output format: text, json or csv")
+	asJSON := fs.Bool("json", false, "Shorthand for --format json")
+	asCSV := fs.Bool("csv", false, "Shorthand for --format csv")
+	return func() (string, error) {
+		switch {
+		case *asJSON:
+			return "json", nil
+		case *asCSV:
+			return "csv", nil
+		case *format != "text" && *format != "json" && *format != "csv":
+			return "", fmt.Errorf("unknown format %q", *format)
+		}
+		return *format, nil
+	}
+}
+
+// writeAircraftList polls m's location and prints the aircraft in range selected by command
+func writeAircraftList(w io.Writer, m *model, command execCommand, format string) error {
+	now := time.Now()
+	if err := m.refreshPlanes(); err != nil {
+		return fmt.Errorf("could not fetch aircraft: %w", err)
+	}
+	var inRange []plane
+	for _, p := range m.planes {
+		if p.DistanceFromObserver <= float64(m.radarRange) {
+			inRange = append(inRange, p)
+		}
+	}
+
+	var aircraft []execAircraft
+	for _, p := range command.selectFn(inRange, now) {
+		aircraft = append(aircraft, newExecAircraft(p, now))
+	}
+
+	switch format {
+	case "json":
+		return writeAircraftJSON(w, m, aircraft, now)
+	case "csv":
+		return writeAircraftCSV(w, aircraft)
+	}
+	return writeAircraftText(w, aircraft)
+}
+
+// runExecCommand prints the aircraft selected by command for the session's options and
+// returns an error for bad options
+func runExecCommand(s ssh.Session, name string, command execCommand, args []string) error {
+	opts := &sessionOptions{}
+	fs := opts.flagSet(name, s.Stderr())
+	resolveFormat := formatFlags(fs)
+	if err := opts.parse(fs, args, s.Environ()); err != nil {
+		return err
+	}
+	format, err := resolveFormat()
+	if err != nil {
+		return err
+	}
+
+	m := newSessionModel(s)
+	if err := opts.apply(m); err != nil {
+		return err
+	}
+	return writeAircraftList(s, m, command, format)
+}
+
+func execUsage() string {
+	names := make([]string, 0, len(execCommands))
+	for name := range execCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("Commands:\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "  %-10s %s\n", name, execCommands[name].description)
+	}
+	b.WriteString("Run a command with --help for its options.")
+	return b.String()
+}
+
+// execMiddleware runs exec commands such as "ssh host nearby --json", which print the
+// aircraft list and exit rather than starting the radar. Sessions without a terminal that
+// don't name a command are shown the list of commands, with a hint to add -t if they were
+// given session options.
+func execMiddleware() wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			args := s.Command()
+			_, _, hasPty := s.Pty()
+
+			var command execCommand
+			var ok bool
+			if len(args) > 0 {
+				command, ok = execCommands[args[0]]
+			}
+			if !ok {
+				if !hasPty && len(args) > 0 && strings.HasPrefix(args[0], "-") {
+					wish.Fatalf(s, "Session options need a terminal, add -t: ssh -t host -- %s\n\n%s\n", strings.Join(args, " "), execUsage())
+					return
+				}
+				if !hasPty {
+					wish.Fatalln(s, execUsage())
+					return
+				}
+				next(s)
+				return
+			}
+
+			err := runExecCommand(s, args[0], command, args[1:])
+			switch {
+			case errors.Is(err, flag.ErrHelp):
+				_ = s.Exit(0)
+			case err != nil:
+				wish.Fatalln(s, err)
+			default:
+				_ = s.Exit(0)
+			}
+		}
+	}
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	maxTrailPoints     = 30
+	rangeCircleSegment = 72
+	earthRadiusNM      = 3440.065
+)
+
+// exportDir is where the export keybindings write their files
+var exportDir = defaultConfig().Server.ExportDir
+
+type trailPoint struct {
+	Lat  float64
+	Lon  float64
+	Time time.Time
+}
+
+// trafficSnapshot is everything written by an export: the aircraft in range, their recent
+// positions and the observer
+type trafficSnapshot struct {
+	Time    time.Time
+	Lat     float64
+	Lon     float64
+	RangeNM int
+	Planes  []plane
+	Trails  map[string][]trailPoint
+}
+
+// recordTrails appends the current position of every plane to its trail, dropping trails
+// for planes that are no longer reported
+func recordTrails(trails map[string][]trailPoint, planes []plane, now time.Time) {
+	seen := make(map[string]bool)
+	for _, p := range planes {
+		seen[p.Hex] = true
+		t := trails[p.Hex]
+		if len(t) > 0 && t[len(t)-1].Lat == p.Lat && t[len(t)-1].Lon == p.Lon {
+			continue
+		}
+		t = append(t, trailPoint{Lat: p.Lat, Lon: p.Lon, Time: p.positionTime(now)})
+		if len(t) > maxTrailPoints {
+			t = t[len(t)-maxTrailPoints:]
+		}
+		trails[p.Hex] = t
+	}
+	for hex := range trails {
+		if !seen[hex] {
+			delete(trails, hex)
+		}
+	}
+}
+
+// destinationPoint returns the point distNM nautical miles from lat, lon along bearing (radians)
+func destinationPoint(lat float64, lon float64, bearing float64, distNM float64) (float64, float64) {
+	lat1 := lat * math.Pi / 180
+	lon1 := lon * math.Pi / 180
+	d := distNM / earthRadiusNM
+
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(d) + math.Cos(lat1)*math.Sin(d)*math.Cos(bearing))
+	lon2 := lon1 + math.Atan2(math.Sin(bearing)*math.Sin(d)*math.Cos(lat1), math.Cos(d)-math.Sin(lat1)*math.Sin(lat2))
+
+	return lat2 * 180 / math.Pi, math.Mod(lon2*180/math.Pi+540, 360) - 180
+}
+
+// rangeCircle returns a closed ring of [lon, lat] pairs around the observer at the radar range
+func (s trafficSnapshot) rangeCircle() [][2]float64 {
+	ring := make([][2]float64, 0, rangeCircleSegment+1)
+	for i := 0; i <= rangeCircleSegment; i++ {
+		bearing := 2 * math.Pi * float64(i%rangeCircleSegment) / rangeCircleSegment
+		lat, lon := destinationPoint(s.Lat, s.Lon, bearing, float64(s.RangeNM))
+		ring = append(ring, [2]float64{lon, lat})
+	}
+	return ring
+}
+
+func trailCoordinates(trail []trailPoint) [][2]float64 {
+	coords := make([][2]float64, len(trail))
+	for i, tp := range trail {
+		coords[i] = [2]float64{tp.Lon, tp.Lat}
+	}
+	return coords
+}
+
+func planeProperties(p plane) map[string]any {
+	return map[string]any{
+		"kind":        "aircraft",
+		"hex":         p.Hex,
+		"flight":      strings.TrimSpace(p.FlightCode),
+		"heading":     p.Heading,
+		"distance_nm": math.Round(p.DistanceFromObserver*100) / 100,
+		"bearing_deg": math.Round(p.BearingFromObserver*180/math.Pi*10) / 10,
+		"noise_dba":   math.Round(p.NoiseLevel),
+		"airline":     p.RouteInfo.Airline,
+		"origin":      p.RouteInfo.OriginMunicipality,
+		"destination": p.RouteInfo.DestMunicipality,
+	}
+}
+
+type geoJSONGeometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+type geoJSONFeature struct {
+	Type       string          `json:"type"`
+	Geometry   geoJSONGeometry `json:"geometry"`
+	Properties map[string]any  `json:"properties"`
+}
+
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+func newFeature(geometryType string, coordinates any, properties map[string]any) geoJSONFeature {
+	return geoJSONFeature{
+		Type:       "Feature",
+		Geometry:   geoJSONGeometry{Type: geometryType, Coordinates: coordinates},
+		Properties: properties,
+	}
+}
+
+func writeGeoJSON(w io.Writer, s trafficSnapshot) error {
+	timestamp := s.Time.UTC().Format(time.RFC3339)
+	features := []geoJSONFeature{
+		newFeature("Point", [2]float64{s.Lon, s.Lat}, map[string]any{
+			"kind":     "observer",
+			"time":     timestamp,
+			"range_nm": s.RangeNM,
+		}),
+		newFeature("Polygon", [][][2]float64{s.rangeCircle()}, map[string]any{
+			"kind":     "range",
+			"range_nm": s.RangeNM,
+		}),
+	}
+
+	for _, p := range s.Planes {
+		props := planeProperties(p)
+		props["time"] = timestamp
+		features = append(features, newFeature("Point", [2]float64{p.Lon, p.Lat}, props))
+
+		trail := s.Trails[p.Hex]
+		if len(trail) < 2 {
+			continue
+		}
+		coords := trailCoordinates(trail)
+		features = append(features, newFeature("LineString", coords, map[string]any{
+			"kind":   "trail",
+			"hex":    p.Hex,
+			"flight": strings.TrimSpace(p.FlightCode),
+			"start":  trail[0].Time.UTC().Format(time.RFC3339),
+			"end":    trail[len(trail)-1].Time.UTC().Format(time.RFC3339),
+		}))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(geoJSONFeatureCollection{Type: "FeatureCollection", Features: features})
+}
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+type kmlExtendedData struct {
+	Data []kmlData `xml:"Data"`
+}
+
+type kmlPoint struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlLineString struct {
+	Tessellate  int    `xml:"tessellate"`
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlPlacemark struct {
+	Name         string           `xml:"name"`
+	Description  string           `xml:"description,omitempty"`
+	ExtendedData *kmlExtendedData `xml:"ExtendedData,omitempty"`
+	Point        *kmlPoint        `xml:"Point,omitempty"`
+	LineString   *kmlLineString   `xml:"LineString,omitempty"`
+}
+
+type kmlDocument struct {
+	XMLName    xml.Name       `xml:"kml"`
+	Namespace  string         `xml:"xmlns,attr"`
+	Name       string         `xml:"Document>name"`
+	Placemarks []kmlPlacemark `xml:"Document>Placemark"`
+}
+
+func kmlCoordinates(points [][2]float64) string {
+	coords := make([]string, len(points))
+	for i, pt := range points {
+		coords[i] = fmt.Sprintf("%f,%f", pt[0], pt[1])
+	}
+	return strings.Join(coords, " ")
+}
+
+func writeKML(w io.Writer, s trafficSnapshot) error {
+	doc := kmlDocument{
+		Namespace: "http://www.opengis.net/kml/2.2",
+		Name:      fmt.Sprintf("WhatPlaneIsThat %s", s.Time.UTC().Format(time.RFC3339)),
+		Placemarks: []kmlPlacemark{
+			{Name: "Observer", Point: &kmlPoint{Coordinates: kmlCoordinates([][2]float64{{s.Lon, s.Lat}})}},
+			{Name: fmt.Sprintf("Range %d NM", s.RangeNM), LineString: &kmlLineString{Tessellate: 1, Coordinates: kmlCoordinates(s.rangeCircle())}},
+		},
+	}
+
+	for _, p := range s.Planes {
+		name := strings.TrimSpace(p.FlightCode)
+		if name == "" {
+			name = p.Hex
+		}
+
+		props := planeProperties(p)
+		keys := make([]string, 0, len(props))
+		for k := range props {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		data := &kmlExtendedData{}
+		for _, k := range keys {
+			data.Data = append(data.Data, kmlData{Name: k, Value: fmt.Sprint(props[k])})
+		}
+		var description string
+		if p.RouteInfo.OriginMunicipality != "" || p.RouteInfo.DestMunicipality != "" {
+			description = fmt.Sprintf("%s %s → %s", p.RouteInfo.Airline, p.RouteInfo.OriginMunicipality, p.RouteInfo.DestMunicipality)
+		}
+		doc.Placemarks = append(doc.Placemarks, kmlPlacemark{
+			Name:         name,
+			Description:  description,
+			ExtendedData: data,
+			Point:        &kmlPoint{Coordinates: kmlCoordinates([][2]float64{{p.Lon, p.Lat}})},
+		})
+
+		trail := s.Trails[p.Hex]
+		if len(trail) < 2 {
+			continue
+		}
+		coords := trailCoordinates(trail)
+		doc.Placemarks = append(doc.Placemarks, kmlPlacemark{Name: name + " trail", LineString: &kmlLineString{Tessellate: 1, Coordinates: kmlCoordinates(coords)}})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// writeSnapshotFile writes s to a timestamped file in dir, named after the session that took
+// it, and returns its path. Existing files are never overwritten.
+func writeSnapshotFile(dir string, name string, format string, s trafficSnapshot) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("traffic-%s-%s.%s", s.Time.Format("20060102-150405"), name, format))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if format == "kml" {
+		err = writeKML(f, s)
+	} else {
+		err = writeGeoJSON(f, s)
+	}
+	return path, err
+}
+
+// snapshot returns the planes currently within range along with their trails
+func (m *model) snapshot() trafficSnapshot {
+	s := trafficSnapshot{
+		Time:    time.Now(),
+		Lat:     m.lat,
+		Lon:     m.lon,
+		RangeNM: m.radarRange,
+		Trails:  m.trails,
+	}
+	for _, p := range m.planes {
+		if p.DistanceFromObserver <= float64(m.radarRange) {
+			s.Planes = append(s.Planes, p)
+		}
+	}
+	return s
+}
+
+func (m *model) exportSnapshot(format string) {
+	if m.guest {
+		m.statusMessage = "Guests can't export snapshots"
+		return
+	}
+	if !m.canExport {
+		m.statusMessage = "Exporting is turned off on this server"
+		return
+	}
+	m.exports++
+	path, err := writeSnapshotFile(exportDir, fmt.Sprintf("%s-%d", m.exportTag, m.exports), format, m.snapshot())
+	if err != nil {
+		log.Printf("Export failed: %v", err)
+		m.statusMessage = "Export failed"
+		return
+	}
+	log.Printf("Exported snapshot to %s", path)
+	m.statusMessage = "Saved " + path
+}
+
+// runExport polls the given location and writes a snapshot to stdout or a file. "export
+// history" dumps the sightings history instead.
+func runExport(args []string) {
+	if len(args) > 0 && args[0] == "history" {
+		runHistoryExport(args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "snapshot" {
+		args = args[1:]
+	}
+
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	latStr := fs.String("lat", "", "Observer latitude, decimal or DMS (default: server default)")
+	lonStr := fs.String("lon", "", "Observer longitude, decimal or DMS (default: server default)")
+	location := fs.String("location", "", "Observer position as a grid square, geohash, plus code or \"lat, lon\"")
+	radarRange := fs.Int("range", 0, "Range in NM (default: server default)")
+	format := fs.String("format", "geojson", "Output format: geojson or kml")
+	samples := fs.Int("samples", 1, "Number of polls to collect trails over")
+	out := fs.String("o", "", "Output file (default: stdout)")
+	configPath := addConfigFlag(fs)
+	fs.Parse(args)
+
+	if *format != "geojson" && *format != "kml" {
+		log.Fatalf("Unknown export format %q", *format)
+	}
+	if _, err := useConfigFile(*configPath); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	defaults := settings().Defaults
+	if *radarRange == 0 {
+		*radarRange = defaults.Range
+	}
+
+	lat, lon, err := parseLocation(*latStr, *lonStr, defaults.Lat, defaults.Lon)
+	if *location != "" {
+		var ok bool
+		if lat, lon, ok, err = parsePosition(*location, defaults.Lat, defaults.Lon); !ok && err == nil {
+			err = fmt.Errorf("unrecognised location %q", *location)
+		}
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	s := trafficSnapshot{Lat: lat, Lon: lon, RangeNM: *radarRange, Trails: make(map[string][]trailPoint)}
+	for i := 0; i < *samples; i++ {
+		if i > 0 {
+			time.Sleep(settings().Sources.PollInterval)
+		}
+		s.Time = time.Now()
+		s.Planes = nil
+		planes, err := sharedFeed.Planes(s.Lat, s.Lon, float64(s.RangeNM))
+		if err != nil {
+			log.Fatalf("Could not fetch aircraft: %v", err)
+		}
+		for _, p := range planes {
+			setPlaneLocationDetails(s.Lat, s.Lon, &p)
+			if p.DistanceFromObserver <= float64(s.RangeNM) {
+				s.Planes = append(s.Planes, p)
+			}
+		}
+		recordTrails(s.Trails, s.Planes, s.Time)
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if *format == "kml" {
+		err = writeKML(w, s)
+	} else {
+		err = writeGeoJSON(w, s)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+type feedEntry struct {
+	planes   []plane
+	polledAt time.Time
+	// err is why the last poll failed, planes then being the last good ones
+	err    error
+	usedAt time.Time
+}
+
+// feedIdleTimeout is how long a location's last poll is kept after nobody has asked for it
+const feedIdleTimeout = 10 * time.Minute
+
+// errTooManyLocations is returned for a new location once --max-feed-locations are being
+// polled
+var errTooManyLocations = errors.New("too many locations are being watched, try again later")
+
+// feedStale reports whether a poll made at polledAt should be repeated. Callers ticking every
+// poll interval ask slightly less than an interval after the last poll finished, so a tenth
+// of the interval is allowed for the round trip.
+func feedStale(polledAt time.Time, now time.Time) bool {
+	interval := settings().Sources.PollInterval
+	return now.Sub(polledAt) >= interval-interval/10
+}
+
+// trafficFeed shares upstream polls between every SSH session and stream subscriber
+// watching the same location and range
+type trafficFeed struct {
+	mu      sync.Mutex
+	entries map[string]*feedEntry
+	polls   singleflight.Group
+}
+
+var sharedFeed = &trafficFeed{entries: make(map[string]*feedEntry)}
+
+func feedKey(lat float64, lon float64, radius float64) string {
+	return fmt.Sprintf("%.4f/%.4f/%.0f", lat, lon, radius)
+}
+
+// Planes returns a copy of the most recent poll for the location, querying upstream
+// if it is older than the configured poll interval. Callers asking for the same location
+// while a poll is running wait for it rather than starting their own. If the poll fails
+// the last good planes are returned along with the error.
+func (f *trafficFeed) Planes(lat float64, lon float64, radius float64) ([]plane, error) {
+	key := feedKey(lat, lon, radius)
+	now := time.Now()
+
+	f.mu.Lock()
+	for k, e := range f.entries {
+		if now.Sub(e.usedAt) > feedIdleTimeout {
+			delete(f.entries, k)
+		}
+	}
+	e, ok := f.entries[key]
+	if ok {
+		e.usedAt = now
+	} else if f.tooManyLocations(now) {
+		f.mu.Unlock()
+		return nil, errTooManyLocations
+	}
+	f.mu.Unlock()
+
+	if !ok || feedStale(e.polledAt, now) {
+		v, _, _ := f.polls.Do(key, func() (any, error) {
+			return f.poll(key, lat, lon, radius), nil
+		})
+		e = v.(*feedEntry)
+	}
+
+	planes := make([]plane, len(e.planes))
+	copy(planes, e.planes)
+	return planes, e.err
+}
+
+// tooManyLocations reports whether as many locations as the limit allows are being polled,
+// counting those asked for within the last two poll intervals. f.mu must be held.
+func (f *trafficFeed) tooManyLocations(now time.Time) bool {
+	limit := settings().Limits.MaxFeedLocations
+	if limit == 0 {
+		return false
+	}
+	active := 0
+	for _, e := range f.entries {
+		if now.Sub(e.usedAt) < 2*settings().Sources.PollInterval {
+			active++
+		}
+	}
+	return active >= limit
+}
+
+// poll queries upstream for the location, without holding the lock so other locations
+// aren't held up
+func (f *trafficFeed) poll(key string, lat float64, lon float64, radius float64) *feedEntry {
+	planes, err := GetLocalFlights(lat, lon, radius)
+	now := time.Now()
+	e := &feedEntry{planes: planes, polledAt: now, err: err, usedAt: now}
+	if err != nil {
+		log.Printf("Polling aircraft at %s failed: %v", key, err)
+	}
+	for i := range e.planes {
+		e.planes[i].PolledAt = now
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if prev, ok := f.entries[key]; ok && err != nil {
+		e.planes = prev.planes
+	}
+	f.entries[key] = e
+	return e
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// The bundled datasets are a small subset of OurAirports and a gazetteer of larger towns.
+// The full OurAirports airports.csv can be loaded in their place with --airports.
+//
+//go:embed data/airports.csv data/places.csv
+var bundledData embed.FS
+
+const maxSearchResults = 6
+
+type gazetteerEntry struct {
+	name         string
+	folded       string
+	detail       string
+	lat          float64
+	lon          float64
+	elevationFt  float64
+	hasElevation bool
+	codes        []string
+	rank         int
+}
+
+type gazetteerIndex struct {
+	mu       sync.Mutex
+	loaded   bool
+	airports []gazetteerEntry
+	places   []gazetteerEntry
+}
+
+var gazetteer = &gazetteerIndex{}
+
+// airportRanks orders airport search results by size, matching the OurAirports type column
+var airportRanks = map[string]int{
+	"large_airport":  3,
+	"medium_airport": 2,
+	"small_airport":  1,
+}
+
+// readCSV reads a CSV file with a header row, returning each row keyed by column name
+func readCSV(r io.Reader) ([]map[string]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []map[string]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(header))
+		for i, column := range header {
+			if i < len(record) {
+				row[column] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// parseAirports reads airports in the OurAirports airports.csv format. Heliports, seaplane
+// bases, balloonports and closed airports are skipped.
+func parseAirports(r io.Reader) ([]gazetteerEntry, error) {
+	rows, err := readCSV(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var airports []gazetteerEntry
+	for _, row := range rows {
+		rank, ok := airportRanks[row["type"]]
+		if !ok {
+			continue
+		}
+		lat, err1 := strconv.ParseFloat(row["latitude_deg"], 64)
+		lon, err2 := strconv.ParseFloat(row["longitude_deg"], 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+
+		entry := gazetteerEntry{
+			name:   row["name"],
+			folded: foldName(row["name"]),
+			detail: strings.TrimSpace(row["municipality"] + " " + row["iso_country"]),
+			lat:    lat,
+			lon:    lon,
+			rank:   rank,
+		}
+		if elev, err := strconv.ParseFloat(row["elevation_ft"], 64); err == nil {
+			entry.elevationFt = elev
+			entry.hasElevation = true
+		}
+		for _, column := range []string{"ident", "icao_code", "iata_code", "gps_code"} {
+			code := strings.ToUpper(strings.TrimSpace(row[column]))
+			if code != "" && !slices.Contains(entry.codes, code) {
+				entry.codes = append(entry.codes, code)
+			}
+		}
+		airports = append(airports, entry)
+	}
+	return airports, nil
+}
+
+// parsePlaces reads towns and cities with name, country, latitude, longitude and population columns
+func parsePlaces(r io.Reader) ([]gazetteerEntry, error) {
+	rows, err := readCSV(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var places []gazetteerEntry
+	for _, row := range rows {
+		lat, err1 := strconv.ParseFloat(row["latitude"], 64)
+		lon, err2 := strconv.ParseFloat(row["longitude"], 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		population, _ := strconv.Atoi(row["population"])
+		places = append(places, gazetteerEntry{
+			name:   row["name"],
+			folded: foldName(row["name"]),
+			detail: row["country"],
+			lat:    lat,
+			lon:    lon,
+			rank:   population,
+		})
+	}
+	return places, nil
+}
+
+func parseDataFile(path string, parse func(io.Reader) ([]gazetteerEntry, error), bundled string) ([]gazetteerEntry, error) {
+	if path == "" {
+		f, err := bundledData.Open(bundled)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return parse(f)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parse(f)
+}
+
+// airportsPath and placesPath replace the bundled datasets when set
+var (
+	airportsPath string
+	placesPath   string
+)
+
+func (g *gazetteerIndex) load() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.loaded {
+		return
+	}
+	g.loaded = true
+
+	var err error
+	if g.airports, err = parseDataFile(airportsPath, parseAirports, "data/airports.csv"); err != nil {
+		log.Printf("Could not load airports: %v", err)
+	}
+	if g.places, err = parseDataFile(placesPath, parsePlaces, "data/places.csv"); err != nil {
+		log.Printf("Could not load places: %v", err)
+	}
+	log.Printf("Loaded %d airports and %d places", len(g.airports), len(g.places))
+}
+
+// foldName lower cases s and strips accents so "reykjavik" matches "Reykjavík"
+func foldName(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, s)
+	if err != nil {
+		folded = s
+	}
+	return strings.ToLower(folded)
+}
+
+type searchMatch struct {
+	entry gazetteerEntry
+	score int
+}
+
+// Search finds airports by ICAO or IATA code and places or airports by name
+func (g *gazetteerIndex) Search(query string) []gazetteerEntry {
+	g.load()
+
+	query = strings.TrimSpace(query)
+	if len(query) < 2 {
+		return nil
+	}
+	code := strings.ToUpper(query)
+	folded := foldName(query)
+
+	var matches []searchMatch
+	for _, airport := range g.airports {
+		score := 0
+		for _, c := range airport.codes {
+			if c == code {
+				score = 4
+			}
+		}
+		if score == 0 {
+			score = nameScore(airport.folded, folded)
+		}
+		if score > 0 {
+			matches = append(matches, searchMatch{airport, score})
+		}
+	}
+	for _, place := range g.places {
+		if score := nameScore(place.folded, folded); score > 0 {
+			matches = append(matches, searchMatch{place, score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].entry.rank > matches[j].entry.rank
+	})
+
+	var results []gazetteerEntry
+	for _, match := range matches {
+		if len(results) == maxSearchResults {
+			break
+		}
+		results = append(results, match.entry)
+	}
+	return results
+}
+
+func nameScore(name string, query string) int {
+	switch {
+	case name == query:
+		return 3
+	case strings.HasPrefix(name, query):
+		return 2
+	case strings.Contains(name, query):
+		return 1
+	}
+	return 0
+}
+
+func (e gazetteerEntry) String() string {
+	label := e.name
+	if len(e.codes) > 0 {
+		label = fmt.Sprintf("%s (%s)", e.name, strings.Join(e.codes, "/"))
+	}
+	return fmt.Sprintf("%s, %s", label, e.detail)
+}
+
+func (m *model) updateSearchResults() {
+	results := gazetteer.Search(m.searchInput.Value())
+	m.searchResults = results
+	if m.searchCursor >= len(results) {
+		m.searchCursor = 0
+	}
+}
+
+// handleSearchKey moves through and picks from the search results, reporting whether the
+// key was used
+func (m *model) handleSearchKey(msg tea.KeyMsg) bool {
+	if len(m.searchResults) == 0 {
+		return false
+	}
+	switch msg.String() {
+	case "up":
+		m.searchCursor = (m.searchCursor - 1 + len(m.searchResults)) % len(m.searchResults)
+		return true
+	case "down":
+		m.searchCursor = (m.searchCursor + 1) % len(m.searchResults)
+		return true
+	case "enter":
+		picked := m.searchResults[m.searchCursor]
+		m.lat = picked.lat
+		m.lon = picked.lon
+		if picked.hasElevation {
+			m.observerElevation = picked.elevationFt
+		}
+		m.statusMessage = "Location set to " + picked.name
+		m.showModal = false
+		m.modalFocused = false
+		m.blurModalInputs()
+		m.resetModalInputs()
+		return true
+	}
+	return false
+}
+
+func (m *model) renderSearchResults() string {
+	if len(m.searchResults) == 0 {
+		if len(strings.TrimSpace(m.searchInput.Value())) >= 2 {
+			return m.renderer.NewStyle().Faint(true).Render("  no matches")
+		}
+		return ""
+	}
+
+	lines := make([]string, len(m.searchResults))
+	for i, result := range m.searchResults {
+		line := ansi.Truncate(result.String(), 50, "…")
+		if i == m.searchCursor {
+			lines[i] = m.renderer.NewStyle().Foreground(lipgloss.Color(m.theme.Bright)).Render("> " + line)
+		} else {
+			lines[i] = "  " + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
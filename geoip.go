@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// geoIPCity is the subset of a GeoLite2/GeoIP2 City record used to place a visitor
+type geoIPCity struct {
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Location struct {
+		Latitude  float64 `maxminddb:"latitude"`
+		Longitude float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+}
+
+// geoIPLocator looks up SSH clients in a MaxMind-format City database
+type geoIPLocator struct {
+	db *maxminddb.Reader
+}
+
+var geoip *geoIPLocator
+
+func openGeoIP(path string) (*geoIPLocator, error) {
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &geoIPLocator{db: db}, nil
+}
+
+func (g *geoIPLocator) Close() error {
+	if g == nil {
+		return nil
+	}
+	return g.db.Close()
+}
+
+// Locate returns the city a remote address is in. Private and loopback addresses, and
+// addresses only known to country level, are not located.
+func (g *geoIPLocator) Locate(addr net.Addr) (float64, float64, string, bool) {
+	if g == nil || addr == nil {
+		return 0, 0, "", false
+	}
+
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		host = addr.String()
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
+		return 0, 0, "", false
+	}
+
+	var record geoIPCity
+	if err := g.db.Lookup(ip, &record); err != nil {
+		log.Printf("GeoIP lookup for %s failed: %v", ip, err)
+		return 0, 0, "", false
+	}
+	city := record.City.Names["en"]
+	if city == "" || (record.Location.Latitude == 0 && record.Location.Longitude == 0) {
+		return 0, 0, "", false
+	}
+	return record.Location.Latitude, record.Location.Longitude, fmt.Sprintf("%s, %s", city, record.Country.ISOCode), true
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sightingGap is how long an aircraft can be out of range before seeing it again
+// counts as a new sighting
+const sightingGap = 5 * time.Minute
+
+const sightingTimeFormat = "2006-01-02T15:04:05Z"
+
+const sightingsSchema = `
+CREATE TABLE IF NOT EXISTS sightings (
+	id                  INTEGER PRIMARY KEY AUTOINCREMENT,
+	hex                 TEXT NOT NULL,
+	callsign            TEXT NOT NULL,
+	airline             TEXT NOT NULL,
+	origin_airport      TEXT NOT NULL,
+	origin_country      TEXT NOT NULL,
+	origin_municipality TEXT NOT NULL,
+	dest_airport        TEXT NOT NULL,
+	dest_country        TEXT NOT NULL,
+	dest_municipality   TEXT NOT NULL,
+	observer_lat        REAL NOT NULL,
+	observer_lon        REAL NOT NULL,
+	first_seen          TEXT NOT NULL,
+	last_seen           TEXT NOT NULL,
+	closest_distance_nm REAL NOT NULL,
+	closest_bearing_deg REAL NOT NULL
+);
+CREATE INDEX IF NOT EXISTS sightings_hex_last_seen ON sightings (hex, last_seen);
+CREATE INDEX IF NOT EXISTS sightings_first_seen ON sightings (first_seen);
+`
+
+// sightingStore persists every aircraft that enters an observer's radar range
+type sightingStore struct {
+	db *sql.DB
+}
+
+// sightings is the server wide history database, nil when history is disabled
+var sightings *sightingStore
+
+func openSightingStore(path string) (*sightingStore, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	// Every SSH session writes to the same file, serialise them rather than fight over locks
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sightingsSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sightingStore{db: db}, nil
+}
+
+func (s *sightingStore) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// Record updates the sighting of every plane in range of the observer, starting a new one
+// for planes that weren't seen within sightingGap
+func (s *sightingStore) Record(lat float64, lon float64, radarRange int, planes []plane, now time.Time) {
+	if s == nil {
+		return
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Printf("Could not record sightings: %v", err)
+		return
+	}
+	defer tx.Rollback()
+
+	nowStr := now.UTC().Format(sightingTimeFormat)
+	since := now.Add(-sightingGap).UTC().Format(sightingTimeFormat)
+
+	for _, p := range planes {
+		if p.DistanceFromObserver > float64(radarRange) {
+			continue
+		}
+		bearingDeg := p.BearingFromObserver * 180 / math.Pi
+
+		var id int64
+		var closest float64
+		err := tx.QueryRow(`
+			SELECT id, closest_distance_nm FROM sightings
+			WHERE hex = ? AND observer_lat = ? AND observer_lon = ? AND last_seen >= ?
+			ORDER BY last_seen DESC LIMIT 1`,
+			p.Hex, lat, lon, since).Scan(&id, &closest)
+
+		switch {
+		case err == sql.ErrNoRows:
+			_, err = tx.Exec(`
+				INSERT INTO sightings (
+					hex, callsign, airline,
+					origin_airport, origin_country, origin_municipality,
+					dest_airport, dest_country, dest_municipality,
+					observer_lat, observer_lon, first_seen, last_seen,
+					closest_distance_nm, closest_bearing_deg
+				) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				p.Hex, strings.TrimSpace(p.FlightCode), p.RouteInfo.Airline,
+				p.RouteInfo.OriginAirport, p.RouteInfo.OriginCountry, p.RouteInfo.OriginMunicipality,
+				p.RouteInfo.DestAirport, p.RouteInfo.DestCountry, p.RouteInfo.DestMunicipality,
+				lat, lon, nowStr, nowStr,
+				p.DistanceFromObserver, bearingDeg)
+		case err != nil:
+		case p.DistanceFromObserver < closest:
+			_, err = tx.Exec(`
+				UPDATE sightings SET last_seen = ?, closest_distance_nm = ?, closest_bearing_deg = ?
+				WHERE id = ?`,
+				nowStr, p.DistanceFromObserver, bearingDeg, id)
+		default:
+			_, err = tx.Exec(`UPDATE sightings SET last_seen = ? WHERE id = ?`, nowStr, id)
+		}
+		if err != nil {
+			log.Printf("Could not record sighting of %s: %v", p.Hex, err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Could not record sightings: %v", err)
+	}
+}
+
+// sightingRecord is a row of the sightings table as written by "export history"
+type sightingRecord struct {
+	FirstSeen          string  `json:"first_seen"`
+	LastSeen           string  `json:"last_seen"`
+	Hex                string  `json:"hex"`
+	Callsign           string  `json:"callsign"`
+	Airline            string  `json:"airline"`
+	OriginAirport      string  `json:"origin_airport"`
+	OriginCountry      string  `json:"origin_country"`
+	OriginMunicipality string  `json:"origin_municipality"`
+	DestAirport        string  `json:"dest_airport"`
+	DestCountry        string  `json:"dest_country"`
+	DestMunicipality   string  `json:"dest_municipality"`
+	ObserverLat        float64 `json:"observer_lat"`
+	ObserverLon        float64 `json:"observer_lon"`
+	ClosestDistanceNM  float64 `json:"closest_distance_nm"`
+	ClosestBearingDeg  float64 `json:"closest_bearing_deg"`
+}
+
+var sightingColumns = []string{
+	"first_seen", "last_seen", "hex", "callsign", "airline",
+	"origin_airport", "origin_country", "origin_municipality",
+	"dest_airport", "dest_country", "dest_municipality",
+	"observer_lat", "observer_lon", "closest_distance_nm", "closest_bearing_deg",
+}
+
+func (r sightingRecord) record() []string {
+	formatFloat := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	return []string{
+		r.FirstSeen, r.LastSeen, r.Hex, r.Callsign, r.Airline,
+		r.OriginAirport, r.OriginCountry, r.OriginMunicipality,
+		r.DestAirport, r.DestCountry, r.DestMunicipality,
+		formatFloat(r.ObserverLat), formatFloat(r.ObserverLon),
+		formatFloat(r.ClosestDistanceNM), formatFloat(r.ClosestBearingDeg),
+	}
+}
+
+// Sightings returns the sightings first seen from since up to until, oldest first. A zero
+// until has no upper bound.
+func (s *sightingStore) Sightings(since time.Time, until time.Time) ([]sightingRecord, error) {
+	query := `SELECT ` + strings.Join(sightingColumns, ", ") + ` FROM sightings WHERE first_seen >= ?`
+	queryArgs := []any{since.UTC().Format(sightingTimeFormat)}
+	if !until.IsZero() {
+		query += ` AND first_seen < ?`
+		queryArgs = append(queryArgs, until.UTC().Format(sightingTimeFormat))
+	}
+	rows, err := s.db.Query(query+` ORDER BY first_seen, id`, queryArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []sightingRecord
+	for rows.Next() {
+		var r sightingRecord
+		err := rows.Scan(&r.FirstSeen, &r.LastSeen, &r.Hex, &r.Callsign, &r.Airline,
+			&r.OriginAirport, &r.OriginCountry, &r.OriginMunicipality,
+			&r.DestAirport, &r.DestCountry, &r.DestMunicipality,
+			&r.ObserverLat, &r.ObserverLon, &r.ClosestDistanceNM, &r.ClosestBearingDeg)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// parseHistoryTime reads an RFC 3339 time or a UTC date
+func parseHistoryTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a date (2025-07-01) or RFC 3339 time (2025-07-01T05:30:00Z)", s)
+	}
+	return t, nil
+}
+
+func writeSightingsCSV(w io.Writer, records []sightingRecord) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(sightingColumns); err != nil {
+		return err
+	}
+	for _, r := range records {
+		if err := cw.Write(r.record()); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeSightingsJSON(w io.Writer, records []sightingRecord) error {
+	if records == nil {
+		records = []sightingRecord{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
+
+// runHistoryExport implements "export history", dumping the sightings history as CSV or JSON
+func runHistoryExport(args []string) {
+	fs := flag.NewFlagSet("export history", flag.ExitOnError)
+	configPath := addConfigFlag(fs)
+	historyPath := fs.String("history", "", "Sightings database (default: the config's history)")
+	sinceStr := fs.String("since", "", "Only sightings first seen at or after this date or time")
+	untilStr := fs.String("until", "", "Only sightings first seen before this date or time")
+	format := fs.String("format", "csv", "Output format: csv or json")
+	out := fs.String("o", "", "Output file (default: stdout)")
+	fs.Parse(args)
+
+	if *format != "csv" && *format != "json" {
+		log.Fatalf("Unknown history format %q", *format)
+	}
+	var since, until time.Time
+	var err error
+	if *sinceStr != "" {
+		if since, err = parseHistoryTime(*sinceStr); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *untilStr != "" {
+		if until, err = parseHistoryTime(*untilStr); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *historyPath == "" {
+		c, err := readConfig(*configPath)
+		if err != nil {
+			log.Fatalf("Invalid configuration: %v", err)
+		}
+		*historyPath = c.Server.History
+	}
+	if *historyPath == "" {
+		log.Fatal("History is disabled, pass --history to name a database")
+	}
+	if _, err := os.Stat(*historyPath); err != nil {
+		log.Fatal(err)
+	}
+
+	store, err := openSightingStore(*historyPath)
+	if err != nil {
+		log.Fatalf("Could not open sightings history %s: %v", *historyPath, err)
+	}
+	defer store.Close()
+	records, err := store.Sightings(since, until)
+	if err != nil {
+		log.Fatalf("Could not read sightings: %v", err)
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if *format == "json" {
+		err = writeSightingsJSON(w, records)
+	} else {
+		err = writeSightingsCSV(w, records)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
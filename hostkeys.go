@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/elliptic"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/keygen"
+	"github.com/charmbracelet/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+var hostKeyTypes = map[string]keygen.KeyType{
+	"ed25519": keygen.Ed25519,
+	"ecdsa":   keygen.ECDSA,
+	"rsa":     keygen.RSA,
+}
+
+// defaultHostKey is where the server has always kept its key. It's used when it exists, so
+// upgrading doesn't change the key clients have already accepted.
+const defaultHostKey = "/var/lib/mysshapp/.ssh/termui_ed25519"
+
+// hostKeyPath is where the host key of a type is kept, named like OpenSSH's
+func hostKeyPath(dir string, keyType string) string {
+	return filepath.Join(dir, "ssh_host_"+keyType+"_key")
+}
+
+// loadHostKey loads the host key at path, generating one of type name if it doesn't exist
+// yet. New keys are written readable only by their owner.
+func loadHostKey(path string, name string) (gossh.Signer, error) {
+	info, err := os.Stat(path)
+	switch {
+	case os.IsNotExist(err):
+		log.Printf("Generating %s host key %s", name, path)
+	case err != nil:
+		return nil, err
+	case info.Mode().Perm()&0o077 != 0:
+		log.Printf("Warning: host key %s can be read by other users, run chmod 600 on it", path)
+	}
+
+	key, err := keygen.New(path, keygen.WithKeyType(hostKeyTypes[name]), keygen.WithEllipticCurve(elliptic.P256()), keygen.WithWrite())
+	if err != nil {
+		return nil, fmt.Errorf("host key %s: %v", path, err)
+	}
+	return key.Signer(), nil
+}
+
+// hostKeyName is the name of a host key's type in hostKeyTypes
+func hostKeyName(signer gossh.Signer) string {
+	for name := range hostKeyTypes {
+		if strings.Contains(signer.PublicKey().Type(), name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// loadHostKeys loads a host key of each of a comma separated list of types from dir,
+// generating any that don't exist yet. keyFile is served in place of the key of its type
+// in dir, and is generated as the first type if it doesn't exist, unless it's the default
+// which is only used when it's there.
+func loadHostKeys(keyFile string, dir string, types string) ([]gossh.Signer, error) {
+	var names []string
+	for _, name := range strings.Split(types, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if _, ok := hostKeyTypes[name]; !ok {
+			return nil, fmt.Errorf("unknown host key type %q, expected ed25519, ecdsa or rsa", name)
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no host key types given")
+	}
+
+	if keyFile == defaultHostKey {
+		if _, err := os.Stat(keyFile); err != nil {
+			keyFile = ""
+		}
+	}
+
+	var signers []gossh.Signer
+	if keyFile != "" {
+		signer, err := loadHostKey(keyFile, names[0])
+		if err != nil {
+			return nil, err
+		}
+		signers = append(signers, signer)
+		names = slices.DeleteFunc(names, func(name string) bool { return name == hostKeyName(signer) })
+	}
+	for _, name := range names {
+		path := hostKeyPath(dir, name)
+		signer, err := loadHostKey(path, name)
+		if err != nil {
+			return nil, err
+		}
+		if hostKeyName(signer) != name {
+			return nil, fmt.Errorf("host key %s is %s, not %s", path, signer.PublicKey().Type(), name)
+		}
+		signers = append(signers, signer)
+	}
+	return signers, nil
+}
+
+// withHostKeys adds host keys to the server and logs their fingerprints so users can check
+// them the first time they connect
+func withHostKeys(signers []gossh.Signer) ssh.Option {
+	return func(srv *ssh.Server) error {
+		for _, signer := range signers {
+			srv.AddHostKey(signer)
+			log.Printf("Host key %s %s", signer.PublicKey().Type(), gossh.FingerprintSHA256(signer.PublicKey()))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// connectionRateWindow is the window --connections-per-minute is counted over
+const connectionRateWindow = time.Minute
+
+// limitConfig caps how many sessions can run at once and how quickly new ones can be
+// opened. Every session runs its own tick, poller and renderer, so these protect the host
+// from a few clients opening lots of them. Zero disables a limit.
+type limitConfig struct {
+	MaxSessions          int           `toml:"max_sessions" yaml:"max_sessions"`
+	MaxSessionsPerIP     int           `toml:"max_sessions_per_ip" yaml:"max_sessions_per_ip"`
+	MaxSessionsPerKey    int           `toml:"max_sessions_per_key" yaml:"max_sessions_per_key"`
+	ConnectionsPerMinute int           `toml:"connections_per_minute" yaml:"connections_per_minute"`
+	IdleTimeout          time.Duration `toml:"idle_timeout" yaml:"idle_timeout"`
+	// Stream subscribers and the locations polled for them and for sessions
+	MaxStreams       int `toml:"max_streams" yaml:"max_streams"`
+	MaxStreamsPerIP  int `toml:"max_streams_per_ip" yaml:"max_streams_per_ip"`
+	MaxFeedLocations int `toml:"max_feed_locations" yaml:"max_feed_locations"`
+}
+
+func (c limitConfig) validate() error {
+	if c.MaxSessions < 0 || c.MaxSessionsPerIP < 0 || c.MaxSessionsPerKey < 0 || c.ConnectionsPerMinute < 0 || c.IdleTimeout < 0 ||
+		c.MaxStreams < 0 || c.MaxStreamsPerIP < 0 || c.MaxFeedLocations < 0 {
+		return fmt.Errorf("limits can't be negative")
+	}
+	return nil
+}
+
+// sessionLimits counts the sessions open against the limits
+type sessionLimits struct {
+	mu          sync.Mutex
+	total       int
+	byIP        map[string]int
+	byKey       map[string]int
+	connections map[string][]time.Time
+	streams     int
+	streamsByIP map[string]int
+}
+
+var limits = &sessionLimits{
+	byIP:        make(map[string]int),
+	byKey:       make(map[string]int),
+	connections: make(map[string][]time.Time),
+	streamsByIP: make(map[string]int),
+}
+
+// sessionModelKey is the session context key the radar's model is kept under, so the
+// middleware can tell why it ended
+type sessionModelKey struct{}
+
+func remoteIP(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// acquire reserves a session for ip and key, returning a message for the client if a limit
+// has been reached
+func (l *sessionLimits) acquire(c limitConfig, ip string, key string, now time.Time) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if c.ConnectionsPerMinute > 0 {
+		recent := l.connections[ip][:0]
+		for _, t := range l.connections[ip] {
+			if now.Sub(t) < connectionRateWindow {
+				recent = append(recent, t)
+			}
+		}
+		l.connections[ip] = append(recent, now)
+		if len(recent) >= c.ConnectionsPerMinute {
+			return "You're connecting too often, please wait a minute and try again.", false
+		}
+	}
+
+	switch {
+	case c.MaxSessions > 0 && l.total >= c.MaxSessions:
+		return "The radar is full right now, please try again later.", false
+	case c.MaxSessionsPerIP > 0 && l.byIP[ip] >= c.MaxSessionsPerIP:
+		return fmt.Sprintf("You already have the maximum of %d sessions open from your address, close one and try again.", l.byIP[ip]), false
+	case key != "" && c.MaxSessionsPerKey > 0 && l.byKey[key] >= c.MaxSessionsPerKey:
+		return fmt.Sprintf("You already have the maximum of %d sessions open with this key, close one and try again.", l.byKey[key]), false
+	}
+
+	l.total++
+	l.byIP[ip]++
+	if key != "" {
+		l.byKey[key]++
+	}
+	return "", true
+}
+
+func (l *sessionLimits) release(ip string, key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.total--
+	if l.byIP[ip]--; l.byIP[ip] <= 0 {
+		delete(l.byIP, ip)
+	}
+	if key != "" {
+		if l.byKey[key]--; l.byKey[key] <= 0 {
+			delete(l.byKey, key)
+		}
+	}
+}
+
+// acquireStream reserves a stream subscription for ip, returning why not if a limit has
+// been reached
+func (l *sessionLimits) acquireStream(c limitConfig, ip string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch {
+	case c.MaxStreams > 0 && l.streams >= c.MaxStreams:
+		return "too many stream subscribers, try again later", false
+	case c.MaxStreamsPerIP > 0 && l.streamsByIP[ip] >= c.MaxStreamsPerIP:
+		return fmt.Sprintf("already at the maximum of %d stream subscribers from your address", l.streamsByIP[ip]), false
+	}
+	l.streams++
+	l.streamsByIP[ip]++
+	return "", true
+}
+
+func (l *sessionLimits) releaseStream(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.streams--
+	if l.streamsByIP[ip]--; l.streamsByIP[ip] <= 0 {
+		delete(l.streamsByIP, ip)
+	}
+}
+
+// prune forgets connection times that have left the rate window
+func (l *sessionLimits) prune(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for ip, times := range l.connections {
+		if len(times) == 0 || now.Sub(times[len(times)-1]) >= connectionRateWindow {
+			delete(l.connections, ip)
+		}
+	}
+}
+
+// limitMiddleware refuses sessions over the limits and tells clients disconnected for
+// being idle why
+func limitMiddleware() wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			ip := remoteIP(s.RemoteAddr())
+			key := ""
+			if pk := s.PublicKey(); pk != nil {
+				key = gossh.FingerprintSHA256(pk)
+			}
+
+			now := time.Now()
+			limits.prune(now)
+			if msg, ok := limits.acquire(settings().Limits, ip, key, now); !ok {
+				log.Printf("Refused session from %s: %s", ip, msg)
+				wish.Fatalln(s, msg)
+				return
+			}
+			defer limits.release(ip, key)
+
+			next(s)
+
+			if m, ok := s.Context().Value(sessionModelKey{}).(*model); ok && m.idleExpired {
+				wish.Printf(s, "Disconnected after %s without input. Reconnect any time.\n", m.idleTimeout)
+			}
+		}
+	}
+}
+
+// idle reports whether the session has had no input for longer than the idle timeout
+func (m *model) idle(now time.Time) bool {
+	return m.idleTimeout > 0 && now.Sub(m.lastInput) > m.idleTimeout
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestLimits() *sessionLimits {
+	return &sessionLimits{
+		byIP:        make(map[string]int),
+		byKey:       make(map[string]int),
+		connections: make(map[string][]time.Time),
+		streamsByIP: make(map[string]int),
+	}
+}
+
+// limitStep opens or, with release set, closes a session at a time after the test starts
+type limitStep struct {
+	release bool
+	ip, key string
+	at      time.Duration
+	want    bool
+}
+
+func TestSessionLimits(t *testing.T) {
+	start := time.Date(2025, 7, 1, 14, 32, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		limits limitConfig
+		steps  []limitStep
+	}{
+		{
+			name:   "total",
+			limits: limitConfig{MaxSessions: 2},
+			steps: []limitStep{
+				{ip: "10.0.0.1", want: true},
+				{ip: "10.0.0.2", want: true},
+				{ip: "10.0.0.3", want: false},
+				{release: true, ip: "10.0.0.1"},
+				{ip: "10.0.0.3", want: true},
+			},
+		},
+		{
+			name:   "per address",
+			limits: limitConfig{MaxSessionsPerIP: 2},
+			steps: []limitStep{
+				{ip: "10.0.0.1", want: true},
+				{ip: "10.0.0.1", want: true},
+				{ip: "10.0.0.1", want: false},
+				{ip: "10.0.0.2", want: true},
+				{release: true, ip: "10.0.0.1"},
+				{ip: "10.0.0.1", want: true},
+			},
+		},
+		{
+			name:   "per key",
+			limits: limitConfig{MaxSessionsPerKey: 1},
+			steps: []limitStep{
+				{ip: "10.0.0.1", key: "SHA256:alice", want: true},
+				{ip: "10.0.0.2", key: "SHA256:alice", want: false},
+				{ip: "10.0.0.2", key: "SHA256:bob", want: true},
+				// Guests have no key to count against
+				{ip: "10.0.0.3", want: true},
+				{ip: "10.0.0.3", want: true},
+				{release: true, ip: "10.0.0.1", key: "SHA256:alice"},
+				{ip: "10.0.0.2", key: "SHA256:alice", want: true},
+			},
+		},
+		{
+			name:   "connection rate",
+			limits: limitConfig{ConnectionsPerMinute: 2},
+			steps: []limitStep{
+				{ip: "10.0.0.1", want: true},
+				{release: true, ip: "10.0.0.1"},
+				{ip: "10.0.0.1", at: 10 * time.Second, want: true},
+				{release: true, ip: "10.0.0.1"},
+				{ip: "10.0.0.1", at: 20 * time.Second, want: false},
+				{ip: "10.0.0.2", at: 20 * time.Second, want: true},
+				// The first connection has left the window, but the refused one still counts
+				{ip: "10.0.0.1", at: time.Minute, want: false},
+				{ip: "10.0.0.1", at: 80 * time.Second, want: true},
+			},
+		},
+		{
+			name:  "no limits",
+			steps: []limitStep{{ip: "10.0.0.1", want: true}, {ip: "10.0.0.1", want: true}, {ip: "10.0.0.1", want: true}},
+		},
+	}
+	for _, tt := range tests {
+		l := newTestLimits()
+		for i, step := range tt.steps {
+			if step.release {
+				l.release(step.ip, step.key)
+				continue
+			}
+			msg, ok := l.acquire(tt.limits, step.ip, step.key, start.Add(step.at))
+			if ok != step.want {
+				t.Errorf("%s: step %d acquire(%s, %q) = %v %q, want %v", tt.name, i, step.ip, step.key, ok, msg, step.want)
+			}
+			if ok != (msg == "") {
+				t.Errorf("%s: step %d acquire(%s, %q) = %v with message %q", tt.name, i, step.ip, step.key, ok, msg)
+			}
+		}
+	}
+}
+
+func TestSessionLimitsRelease(t *testing.T) {
+	l := newTestLimits()
+	c := limitConfig{MaxSessions: 10}
+	l.acquire(c, "10.0.0.1", "SHA256:alice", time.Now())
+	l.acquire(c, "10.0.0.1", "", time.Now())
+	l.release("10.0.0.1", "SHA256:alice")
+	l.release("10.0.0.1", "")
+	if l.total != 0 || len(l.byIP) != 0 || len(l.byKey) != 0 {
+		t.Errorf("after releasing every session total %d, by address %v, by key %v", l.total, l.byIP, l.byKey)
+	}
+}
+
+func TestSessionLimitsPrune(t *testing.T) {
+	start := time.Date(2025, 7, 1, 14, 32, 0, 0, time.UTC)
+	l := newTestLimits()
+	c := limitConfig{ConnectionsPerMinute: 5}
+	l.acquire(c, "10.0.0.1", "", start)
+	l.acquire(c, "10.0.0.2", "", start.Add(30*time.Second))
+
+	l.prune(start.Add(time.Minute))
+	if _, ok := l.connections["10.0.0.1"]; ok {
+		t.Errorf("prune kept 10.0.0.1, last connected a minute ago")
+	}
+	if _, ok := l.connections["10.0.0.2"]; !ok {
+		t.Errorf("prune forgot 10.0.0.2, last connected 30s ago")
+	}
+}
+
+func TestStreamLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits limitConfig
+		steps  []limitStep
+	}{
+		{
+			name:   "total",
+			limits: limitConfig{MaxStreams: 2},
+			steps: []limitStep{
+				{ip: "10.0.0.1", want: true},
+				{ip: "10.0.0.2", want: true},
+				{ip: "10.0.0.3", want: false},
+				{release: true, ip: "10.0.0.2"},
+				{ip: "10.0.0.3", want: true},
+			},
+		},
+		{
+			name:   "per address",
+			limits: limitConfig{MaxStreamsPerIP: 1},
+			steps: []limitStep{
+				{ip: "10.0.0.1", want: true},
+				{ip: "10.0.0.1", want: false},
+				{ip: "10.0.0.2", want: true},
+				{release: true, ip: "10.0.0.1"},
+				{ip: "10.0.0.1", want: true},
+			},
+		},
+		{
+			// Streams are counted apart from sessions
+			name:   "sessions",
+			limits: limitConfig{MaxSessions: 1, MaxStreams: 1},
+			steps:  []limitStep{{ip: "10.0.0.1", want: true}},
+		},
+	}
+	for _, tt := range tests {
+		l := newTestLimits()
+		l.acquire(tt.limits, "10.0.0.9", "", time.Now())
+		for i, step := range tt.steps {
+			if step.release {
+				l.releaseStream(step.ip)
+				continue
+			}
+			msg, ok := l.acquireStream(tt.limits, step.ip)
+			if ok != step.want {
+				t.Errorf("%s: step %d acquireStream(%s) = %v %q, want %v", tt.name, i, step.ip, ok, msg, step.want)
+			}
+		}
+	}
+}
@@ -8,9 +8,12 @@ import (
 	"log"
 	"math"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"sort"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -23,30 +26,16 @@ import (
 	"github.com/charmbracelet/wish/activeterm"
 	"github.com/charmbracelet/wish/bubbletea"
 	"github.com/charmbracelet/wish/logging"
+	"github.com/charmbracelet/x/ansi"
 
 	"github.com/muesli/termenv"
 	"github.com/umahmood/haversine"
-	gossh "golang.org/x/crypto/ssh"
 )
 
-var brightGreen = lipgloss.Color("#00ff00")
-var mediumGreen = lipgloss.Color("#00bc00")
-var dimGreen = lipgloss.Color("#007900")
-var dimmestGreen = lipgloss.Color("#001b00")
-
-var frameBg = lipgloss.NewStyle().Background(lipgloss.Color("#3b3a3a"))
-
-var baseStyle = lipgloss.NewStyle().
-	BorderStyle(lipgloss.NormalBorder()).
-	BorderForeground(lipgloss.Color("240"))
-
+// The default location, ranges, aspect ratio and tick interval are set in the config file,
+// see config.go
 const (
 	MIN_RADAR_RANGE      = 1
-	MAX_RADAR_RANGE      = 200
-	DEFAULT_RADAR_RANGE  = 15
-	DEFAULT_ASPECT_RATIO = 0.5
-	DEFAULT_LAT          = 53.79538
-	DEFAULT_LON          = -1.66134
 	DEFAULT_NORTH_OFFSET = 0.0
 )
 
@@ -62,27 +51,64 @@ type model struct {
 	planes              []plane
 	visiblePlanes       map[string]bool
 
-	lat            float64
-	lon            float64
-	showModal      bool
-	tbl            table.Model
-	tableLoaded    bool
+	lat               float64
+	lon               float64
+	observerElevation float64
+	showModal         bool
+	tbl               table.Model
+	tableLoaded       bool
+	// tableRows have a cell for each of planeColumns, tableColumns being the ones shown
+	tableRows      []table.Row
+	tableColumns   []int
 	latInput       textinput.Model
 	lonInput       textinput.Model
+	elevInput      textinput.Model
 	modalFocused   bool
 	getLiveFlights bool
+	trails         map[string][]trailPoint
+	statusMessage  string
+	showStats      bool
+	acousticMode   bool
+	// heard is the likely audible aircraft, worked out after each poll
+	heard           acousticEstimate
+	heardOK         bool
+	viewingSectors  []viewingSector
+	showSectorModal bool
+	sectorInput     textinput.Model
+	sectorErr       string
+	searchInput     textinput.Model
+	searchResults   []gazetteerEntry
+	searchCursor    int
+	locationErr     string
+	profileKey      string
+	guest           bool
+	locationLocked  bool
+	maxRadarRange   int
+	idleTimeout     time.Duration
+	lastInput       time.Time
+	idleExpired     bool
+	themeName       string
+	theme           theme
+	renderer        *lipgloss.Renderer
+	colors          termenv.Profile
+	radarRenderer   string
+	// canExport lets e/E write snapshots, named after exportTag and counted by exports
+	canExport bool
+	exportTag string
+	exports   int
 }
 
 type cell struct {
 	char     rune
 	kind     string
 	sweepAge int
+	inSector bool
 }
 
 type tickMsg time.Time
 
 func doTick() tea.Cmd {
-	return tea.Tick(time.Millisecond*200, func(t time.Time) tea.Msg {
+	return tea.Tick(settings().Defaults.TickInterval, func(t time.Time) tea.Msg {
 		return tickMsg(t)
 	})
 }
@@ -100,13 +126,13 @@ func (m model) Init() tea.Cmd {
 	return doTick()
 }
 
-func (m *model) GetPlanes() []plane {
+func (m *model) GetPlanes() ([]plane, error) {
 	if m.getLiveFlights {
-		planes := GetLocalFlights(m.lat, m.lon, float64(m.radarRange))
+		planes, err := sharedFeed.Planes(m.lat, m.lon, float64(m.radarRange))
 		for i := range planes {
 			m.SetPlaneLocationDetails(&planes[i])
 		}
-		return planes
+		return planes, err
 	}
 
 	baseLat := m.lat
@@ -120,11 +146,34 @@ func (m *model) GetPlanes() []plane {
 	for i := range planes {
 		m.SetPlaneLocationDetails(&planes[i])
 	}
-	return planes
+	return planes, nil
+}
+
+const fetchErrorPrefix = "Could not fetch aircraft: "
+
+// refreshPlanes fetches the latest planes, extends their trails and records their sightings.
+// If the poll fails the radar keeps showing the last good planes, without recording them
+// again as if they'd just been seen, and the error is returned.
+func (m *model) refreshPlanes() error {
+	now := time.Now()
+	planes, err := m.GetPlanes()
+	m.planes = planes
+	m.heard, m.heardOK = m.likelyAudible(now)
+	if err != nil {
+		m.statusMessage = fetchErrorPrefix + err.Error()
+		return err
+	}
+	if strings.HasPrefix(m.statusMessage, fetchErrorPrefix) {
+		m.statusMessage = ""
+	}
+	recordTrails(m.trails, m.planes, now)
+	sightings.Record(m.lat, m.lon, m.radarRange, m.planes, now)
+	statsFor(m.lat, m.lon).Record(m.planes, m.radarRange, now)
+	return nil
 }
 
 func (m *model) UpdatePlaneRow(p plane) tea.Cmd {
-	rows := m.tbl.Rows()
+	rows := m.tableRows
 
 	index := -1
 	for i := range rows {
@@ -133,13 +182,7 @@ func (m *model) UpdatePlaneRow(p plane) tea.Cmd {
 		}
 	}
 
-	newRow := table.Row{
-		p.FlightCode,
-		p.RouteInfo.Airline,
-		p.RouteInfo.OriginMunicipality,
-		p.RouteInfo.DestMunicipality,
-		fmt.Sprintf("%.2f", p.DistanceFromObserver),
-	}
+	newRow := planeRow(p, time.Now())
 
 	var newRows []table.Row
 	if index == -1 {
@@ -148,58 +191,94 @@ func (m *model) UpdatePlaneRow(p plane) tea.Cmd {
 		newRows = append([]table.Row{newRow}, append(rows[:index], rows[index+1:]...)...)
 	}
 
-	m.tbl.SetRows(newRows)
+	m.setTableRows(newRows)
 
 	return nil
 }
 
+// modalInputs returns the location modal inputs in tab order
+func (m *model) modalInputs() []*textinput.Model {
+	return []*textinput.Model{&m.searchInput, &m.latInput, &m.lonInput, &m.elevInput}
+}
+
+func (m *model) blurModalInputs() {
+	for _, input := range m.modalInputs() {
+		input.Blur()
+	}
+}
+
+// resetModalInputs fills the modal inputs with the current observer settings
+func (m *model) resetModalInputs() {
+	m.latInput.SetValue(fmt.Sprintf("%.4f", m.lat))
+	m.lonInput.SetValue(fmt.Sprintf("%.4f", m.lon))
+	m.elevInput.SetValue(fmt.Sprintf("%.0f", m.observerElevation))
+	m.searchInput.SetValue("")
+	m.searchResults = nil
+	m.searchCursor = 0
+	m.locationErr = ""
+}
+
 func (m *model) handleModalInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.searchInput.Focused() {
+		if handled := m.handleSearchKey(msg); handled {
+			return m, nil
+		}
+	}
+
 	switch msg.String() {
 	case "tab":
-		// Switch focus between inputs
-		if m.latInput.Focused() {
-			m.latInput.Blur()
-			m.lonInput.Focus()
-		} else {
-			m.lonInput.Blur()
-			m.latInput.Focus()
+		// Move focus to the next input
+		inputs := m.modalInputs()
+		for i, input := range inputs {
+			if input.Focused() {
+				input.Blur()
+				inputs[(i+1)%len(inputs)].Focus()
+				break
+			}
 		}
 		return m, nil
 	case "enter":
-		// Apply the new coordinates
-		if latStr := m.latInput.Value(); latStr != "" {
-			if lat, err := strconv.ParseFloat(latStr, 64); err == nil {
-				m.lat = lat
-			}
+		// Apply the new coordinates, keeping the modal open to show any errors
+		lat, lon, err := parseLocation(m.latInput.Value(), m.lonInput.Value(), m.lat, m.lon)
+		if err != nil {
+			m.locationErr = err.Error()
+			return m, nil
 		}
-		if lonStr := m.lonInput.Value(); lonStr != "" {
-			if lon, err := strconv.ParseFloat(lonStr, 64); err == nil {
-				m.lon = lon
+		elevation := m.observerElevation
+		if elevStr := strings.TrimSpace(m.elevInput.Value()); elevStr != "" {
+			if elevation, err = strconv.ParseFloat(elevStr, 64); err != nil {
+				m.locationErr = fmt.Sprintf("invalid elevation %q", elevStr)
+				return m, nil
 			}
 		}
+		m.lat = lat
+		m.lon = lon
+		m.observerElevation = elevation
+		m.locationErr = ""
 		m.showModal = false
 		m.modalFocused = false
-		m.latInput.Blur()
-		m.lonInput.Blur()
+		m.blurModalInputs()
 		return m, nil
 	case "esc":
 		// Cancel and close modal
 		m.showModal = false
 		m.modalFocused = false
-		m.latInput.Blur()
-		m.lonInput.Blur()
+		m.blurModalInputs()
 		// Reset inputs to current values
-		m.latInput.SetValue(fmt.Sprintf("%.4f", m.lat))
-		m.lonInput.SetValue(fmt.Sprintf("%.4f", m.lon))
+		m.resetModalInputs()
 		return m, nil
 	}
 
 	// Update the focused input
 	var cmd tea.Cmd
-	if m.latInput.Focused() {
-		m.latInput, cmd = m.latInput.Update(msg)
-	} else if m.lonInput.Focused() {
-		m.lonInput, cmd = m.lonInput.Update(msg)
+	for _, input := range m.modalInputs() {
+		if input.Focused() {
+			*input, cmd = input.Update(msg)
+			break
+		}
+	}
+	if m.searchInput.Focused() {
+		m.updateSearchResults()
 	}
 	return m, cmd
 }
@@ -215,12 +294,13 @@ func (m *model) handleKeyInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.northOffset -= 0.1
 		return m, nil
 	case "=":
-		if m.radarRange < MAX_RADAR_RANGE {
+		if m.radarRange < m.maxRadarRange {
 			if m.radarRange == 1 {
 				m.radarRange = 5
 			} else {
 				m.radarRange += 5
 			}
+			m.radarRange = min(m.radarRange, m.maxRadarRange)
 		}
 		return m, nil
 	case "-":
@@ -232,17 +312,46 @@ func (m *model) handleKeyInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			}
 		}
 		return m, nil
+	case "s":
+		m.showStats = !m.showStats
+		return m, nil
+	case "a":
+		m.acousticMode = !m.acousticMode
+		return m, nil
+	case "h":
+		m.identifyHeard()
+		return m, nil
+	case "v":
+		m.openSectorModal()
+		return m, nil
+	case "e":
+		m.exportSnapshot("geojson")
+		return m, nil
+	case "E":
+		m.exportSnapshot("kml")
+		return m, nil
+	case "P":
+		m.saveProfile()
+		return m, nil
+	case "t":
+		m.cycleTheme()
+		return m, nil
+	case "r":
+		m.cycleRadarRenderer()
+		return m, nil
 	case "m":
+		if m.locationLocked {
+			m.statusMessage = "Guests can't change the location"
+			return m, nil
+		}
 		m.showModal = !m.showModal
 		if m.showModal {
 			m.modalFocused = true
-			m.latInput.Focus()
-			m.latInput.SetValue(fmt.Sprintf("%.4f", m.lat))
-			m.lonInput.SetValue(fmt.Sprintf("%.4f", m.lon))
+			m.searchInput.Focus()
+			m.resetModalInputs()
 		} else {
 			m.modalFocused = false
-			m.latInput.Blur()
-			m.lonInput.Blur()
+			m.blurModalInputs()
 		}
 		return m, nil
 	}
@@ -253,70 +362,41 @@ func (m *model) handleWindowResize(msg tea.WindowSizeMsg) (tea.Model, tea.Cmd) {
 	m.width = msg.Width
 	m.height = msg.Height
 
-	m.buffer = make([][]cell, m.height)
-	for y := range m.buffer {
-		m.buffer[y] = make([]cell, m.width/2)
-		for x := range m.buffer[y] {
-			m.buffer[y][x] = cell{' ', "blank", int(100)}
-		}
-	}
+	m.buffer = newCellGrid(m.width/2, m.height)
 
 	if !m.tableLoaded {
-		columns := []table.Column{
-			{Title: "FLT", Width: 8},
-			{Title: "AIRLINE", Width: 16},
-			{Title: "ORIGIN", Width: 18},
-			{Title: "DEST", Width: 18},
-			{Title: "DIST(NM)", Width: 10},
-		}
-		rows := []table.Row{}
-
 		tableHeight := m.height / 2
 		if tableHeight < 5 {
 			tableHeight = 5
 		}
 
-		tableWidth := 0
-		for _, column := range columns {
-			tableWidth += column.Width
-		}
-		tableWidth += 10
-
 		m.tbl = table.New(
-			table.WithColumns(columns),
-			table.WithRows(rows),
 			table.WithFocused(true),
 			table.WithHeight(tableHeight),
-			table.WithWidth(tableWidth),
 		)
 
-		// Set default styles with basic customization
-		s := table.DefaultStyles()
-		s.Header = s.Header.
-			BorderStyle(lipgloss.NormalBorder()).
-			BorderForeground(lipgloss.Color("240")).
-			BorderBottom(true).
-			Bold(false)
-		s.Selected = s.Selected.
-			Foreground(lipgloss.Color("black")).
-			Background(mediumGreen).
-			Bold(false)
-		m.tbl.SetStyles(s)
+		m.tbl.SetStyles(m.tableStyles())
 
 		m.tableLoaded = true
 	}
+	m.layoutTable()
 	if !m.initialPlanesLoaded {
-		m.planes = m.GetPlanes()
+		m.refreshPlanes()
 		m.initialPlanesLoaded = true
 	}
 	return m, nil
 }
 
 func (m *model) handleTickMsg() (tea.Model, tea.Cmd) {
-	m.sweepAngle += 0.1
+	if m.idle(time.Now()) {
+		m.idleExpired = true
+		return m, tea.Quit
+	}
+
+	m.sweepAngle += sweepStep
 	if m.sweepAngle >= 2*math.Pi {
 		m.sweepAngle = 0
-		m.planes = m.GetPlanes()
+		m.refreshPlanes()
 	}
 
 	for y := range m.buffer {
@@ -345,15 +425,14 @@ func (m *model) handleTickMsg() (tea.Model, tea.Cmd) {
 	}
 
 	// Remove planes from table that are no longer visible
-	rows := m.tbl.Rows()
 	var newRows []table.Row
-	for _, row := range rows {
+	for _, row := range m.tableRows {
 		flightCode := row[0]
 		if currentVisible[flightCode] {
 			newRows = append(newRows, row)
 		}
 	}
-	m.tbl.SetRows(newRows)
+	m.setTableRows(newRows)
 
 	m.visiblePlanes = currentInSweep
 	return m, doTick()
@@ -369,9 +448,13 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		m.lastInput = time.Now()
 		if m.showModal && m.modalFocused {
 			return m.handleModalInput(msg)
 		}
+		if m.showSectorModal {
+			return m.handleSectorInput(msg)
+		}
 		return m.handleKeyInput(msg)
 	case tea.WindowSizeMsg:
 		return m.handleWindowResize(msg)
@@ -383,17 +466,34 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m model) SetPlaneLocationDetails(p *plane) {
-	curr_location := haversine.Coord{Lat: m.lat, Lon: m.lon}
-	planeLocation := haversine.Coord{Lat: p.Lat, Lon: p.Lon}
+	setPlaneLocationDetails(m.lat, m.lon, p)
+	setSkyPosition(p, m.observerElevation)
+	p.NoiseLevel, p.Audible = estimateNoise(*p, p.SlantRange)
+	p.InSector = m.inViewingSector(*p)
+}
+
+// setPlaneLocationDetails sets the distance and bearing of p from an observer at lat, lon
+func setPlaneLocationDetails(lat float64, lon float64, p *plane) {
+	nm, bearing := distanceAndBearing(lat, lon, p.Lat, p.Lon)
+	p.DistanceFromObserver = nm
+	p.BearingFromObserver = bearing
+	setClosestApproach(p, time.Now())
+	log.Printf("SetPlane: lat=%.4f, lon=%.4f → bearing=%.4f, dist=%.4f",
+		p.Lat, p.Lon, bearing, nm)
+}
+
+// distanceAndBearing returns the distance in NM and the bearing in radians from the first
+// position to the second
+func distanceAndBearing(lat0 float64, lon0 float64, lat1 float64, lon1 float64) (float64, float64) {
+	curr_location := haversine.Coord{Lat: lat0, Lon: lon0}
+	planeLocation := haversine.Coord{Lat: lat1, Lon: lon1}
 
 	mi, _ := haversine.Distance(curr_location, planeLocation)
 	nm := mi / 1.15078
 
-	p.DistanceFromObserver = nm
-
-	lat0Rad := m.lat * math.Pi / 180
-	lat1Rad := p.Lat * math.Pi / 180
-	dLonRad := (p.Lon - m.lon) * math.Pi / 180
+	lat0Rad := lat0 * math.Pi / 180
+	lat1Rad := lat1 * math.Pi / 180
+	dLonRad := (lon1 - lon0) * math.Pi / 180
 
 	y := math.Sin(dLonRad) * math.Cos(lat1Rad)
 	x := math.Cos(lat0Rad)*math.Sin(lat1Rad) - math.Sin(lat0Rad)*math.Cos(lat1Rad)*math.Cos(dLonRad)
@@ -401,9 +501,7 @@ func (m model) SetPlaneLocationDetails(p *plane) {
 	if bearing < 0 {
 		bearing += 2 * math.Pi
 	}
-	p.BearingFromObserver = bearing
-	log.Printf("SetPlane: lat=%.4f, lon=%.4f → bearing=%.4f, dist=%.4f",
-		p.Lat, p.Lon, bearing, nm)
+	return nm, bearing
 }
 
 func (m *model) View() string {
@@ -416,19 +514,44 @@ func (m *model) View() string {
 		bearingDegrees += 360
 	}
 
-	statusBar := lipgloss.NewStyle().
-		Background(lipgloss.Color("235")).
+	status := m.nextOverheadStatus(time.Now()) + " | " + m.loudestStatus() + " | "
+	if m.acousticMode {
+		if m.heardOK {
+			status += m.heard.describe() + " | "
+		} else {
+			status += "hearing: nothing within earshot | "
+		}
+	}
+	status += fmt.Sprintf("Range: %d NM  -\\= |  Bearing: %.0f° [\\] |  lat: %f   lon: %f  m to change | s stats | a/h hearing | v view | e/E export | t theme | r renderer | P save", m.radarRange, bearingDegrees, m.lat, m.lon)
+	if m.statusMessage != "" {
+		status += " | " + m.statusMessage
+	}
+
+	statusBar := m.renderer.NewStyle().
+		Background(lipgloss.Color(m.theme.StatusBar)).
 		Height(1).
 		Width(m.width).
-		Render(fmt.Sprintf("Range: %d NM  -\\= |  Bearing: %.0f° [\\] |  lat: %f   lon: %f  m to change", m.radarRange, bearingDegrees, m.lat, m.lon))
+		Render(ansi.Truncate(status, m.width, "…"))
 
-	radar := m.renderRadar(m.width/2, m.height)
-	tableStr := lipgloss.NewStyle().
+	var radar string
+	if m.showStats {
+		radar = m.renderStats(m.width/2, m.height)
+	} else {
+		radar = m.renderRadar(m.width/2, m.height)
+	}
+	tableStr := m.renderer.NewStyle().
 		Height(m.height).
 		Width(m.width / 2).
 		AlignVertical(lipgloss.Center).
 		AlignHorizontal(lipgloss.Center).
-		Render(baseStyle.Render(m.tbl.View()))
+		Render(lipgloss.JoinVertical(
+			lipgloss.Center,
+			m.renderer.NewStyle().
+				BorderStyle(lipgloss.NormalBorder()).
+				BorderForeground(lipgloss.Color(m.theme.Border)).
+				Render(m.tbl.View()),
+			m.renderer.NewStyle().Foreground(lipgloss.Color(m.theme.Medium)).Render(m.lookHint()),
+		))
 
 	main := lipgloss.JoinVertical(
 		lipgloss.Left,
@@ -441,33 +564,50 @@ func (m *model) View() string {
 		statusBar,
 	)
 
+	if m.showSectorModal {
+		return m.renderer.Place(
+			m.width,
+			m.height,
+			lipgloss.Center,
+			lipgloss.Center,
+			m.renderSectorModal(),
+		)
+	}
+
 	if m.showModal {
 		// Create modal content with text inputs
 		modalContent := lipgloss.JoinVertical(
 			lipgloss.Left,
-			lipgloss.NewStyle().Bold(true).Render("Set Observer Location"),
+			m.renderer.NewStyle().Bold(true).Render("Set Observer Location"),
 			"",
-			"Latitude:",
+			"Search town or airport code:",
+			m.searchInput.View(),
+			m.renderSearchResults(),
+			"",
+			"Latitude, or grid square, geohash or plus code:",
 			m.latInput.View(),
 			"",
 			"Longitude:",
 			m.lonInput.View(),
 			"",
-			lipgloss.NewStyle().Faint(true).Render("Tab: Switch | Enter: Apply | Esc: Cancel"),
+			"Elevation (ft):",
+			m.elevInput.View(),
+			m.renderer.NewStyle().Foreground(lipgloss.Color(m.theme.Error)).Render(m.locationErr),
+			m.renderer.NewStyle().Faint(true).Render("Tab: Switch | ↑/↓: Pick | Enter: Apply | Esc: Cancel"),
 		)
 
 		// Overlay modal on top of main UI
-		overlayModal := lipgloss.NewStyle().
+		overlayModal := m.renderer.NewStyle().
 			Border(lipgloss.NormalBorder()).
 			Padding(1, 2).
 			Background(lipgloss.Color("#222")).
 			Foreground(lipgloss.Color("#fff")).
 			Align(lipgloss.Center, lipgloss.Center).
-			Width(50).
-			Height(12).
+			Width(60).
+			Height(26).
 			Render(modalContent)
 
-		return lipgloss.Place(
+		return m.renderer.Place(
 			m.width,
 			m.height,
 			lipgloss.Center,
@@ -483,52 +623,174 @@ func (m *model) View() string {
 func newModel() *model {
 	latInput := textinput.New()
 	latInput.Placeholder = "40.7128"
-	latInput.CharLimit = 10
-	latInput.Width = 15
+	latInput.CharLimit = 40
+	latInput.Width = 30
 
 	lonInput := textinput.New()
 	lonInput.Placeholder = "-74.0060"
-	lonInput.CharLimit = 11
-	lonInput.Width = 15
-
-	return &model{
-		radarRange:          DEFAULT_RADAR_RANGE,
-		aspectRatio:         DEFAULT_ASPECT_RATIO,
-		lat:                 DEFAULT_LAT,
-		lon:                 DEFAULT_LON,
+	lonInput.CharLimit = 20
+	lonInput.Width = 30
+
+	searchInput := textinput.New()
+	searchInput.Placeholder = "Leeds, EGNM or LBA"
+	searchInput.CharLimit = 40
+	searchInput.Width = 40
+
+	sectorInput := textinput.New()
+	sectorInput.Placeholder = "120-210@15"
+	sectorInput.CharLimit = 100
+	sectorInput.Width = 40
+
+	elevInput := textinput.New()
+	elevInput.Placeholder = "0"
+	elevInput.CharLimit = 6
+	elevInput.Width = 15
+
+	defaults := settings().Defaults
+	m := &model{
+		radarRange:          defaults.Range,
+		maxRadarRange:       defaults.MaxRange,
+		aspectRatio:         defaults.AspectRatio,
+		lat:                 defaults.Lat,
+		lon:                 defaults.Lon,
 		initialPlanesLoaded: false,
 		tableLoaded:         false,
 		visiblePlanes:       make(map[string]bool),
 		showModal:           false,
 		latInput:            latInput,
 		lonInput:            lonInput,
+		elevInput:           elevInput,
+		sectorInput:         sectorInput,
+		searchInput:         searchInput,
 		modalFocused:        false,
 		getLiveFlights:      true,
+		trails:              make(map[string][]trailPoint),
+		lastInput:           time.Now(),
+		// Sessions and the tui replace these with their terminal's
+		renderer:      lipgloss.NewRenderer(os.Stdout),
+		colors:        termenv.TrueColor,
+		radarRenderer: defaults.Renderer,
+	}
+	if !m.setTheme(defaults.Theme) {
+		m.setTheme(defaultTheme)
 	}
+	return m
+}
+
+// subcommand is run when its name is the first argument
+type subcommand struct {
+	description string
+	run         func(args []string)
+}
+
+var subcommands = map[string]subcommand{
+	"serve":  {"run the SSH server (the default)", runServe},
+	"tui":    {"run the radar in this terminal", runTUI},
+	"query":  {"print the aircraft near a location and exit", runQuery},
+	"import": {"load an airport, place, airline or aircraft dataset", runImport},
+	"export": {"write a traffic snapshot, or the sightings history", runExport},
+	"config": {"check a config file", runConfig},
+}
+
+func usage() string {
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("usage: whatplaneisthat [command] [flags]\n\nCommands:\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "  %-8s %s\n", name, subcommands[name].description)
+	}
+	b.WriteString("Run a command with --help for its flags.")
+	return b.String()
 }
 
 func main() {
-	var host string
-	var port string
-	flag.StringVar(&host, "host", "", "Host to listen on (default: all interfaces)")
-	flag.StringVar(&port, "port", "22", "Port to listen on (default: 22)")
-	flag.Parse()
+	// Without a command the server starts, so flag only invocations keep working
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		fmt.Println(usage())
+		return
+	}
+	cmd, ok := subcommands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n%s\n", name, usage())
+		os.Exit(2)
+	}
+	cmd.run(args)
+}
 
-	os.Setenv("TERM", "xterm-256color")
-	os.Setenv("COLORTERM", "truecolor")
+// runServe runs the SSH server, and the stream server if enabled, until interrupted
+func runServe(args []string) {
+	c, configPath, err := loadConfig("serve", args)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err == nil {
+		err = c.validate()
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	currentConfig.Store(c)
+	if configPath != "" {
+		log.Printf("Loaded config %s", configPath)
+	}
+
+	exportDir = c.Server.ExportDir
+	useDataConfig(c.Data)
+	host, port := c.Server.Host, c.Server.Port
+	streamAddr := c.Server.Stream
+	historyPath, profilesPath, geoipPath := c.Server.History, c.Server.Profiles, c.Server.GeoIP
+
+	if geoipPath != "" {
+		locator, err := openGeoIP(geoipPath)
+		if err != nil {
+			log.Fatalf("Could not open GeoIP database %s: %v", geoipPath, err)
+		}
+		geoip = locator
+		defer geoip.Close()
+	}
+
+	if historyPath != "" {
+		store, err := openSightingStore(historyPath)
+		if err != nil {
+			log.Fatalf("Could not open sightings history %s: %v", historyPath, err)
+		}
+		sightings = store
+		defer sightings.Close()
+	}
+
+	if profilesPath != "" {
+		store, err := openProfileStore(profilesPath)
+		if err != nil {
+			log.Fatalf("Could not open profiles %s: %v", profilesPath, err)
+		}
+		profiles = store
+		defer profiles.Close()
+	}
+
+	hostKeys, err := loadHostKeys(c.Server.HostKey, c.Server.HostKeyDir, c.Server.HostKeyTypes)
+	if err != nil {
+		log.Fatalf("Could not load host keys: %v", err)
+	}
 
 	s, err := wish.NewServer(
 		wish.WithAddress(net.JoinHostPort(host, port)),
-		wish.WithHostKeyPath("/var/lib/mysshapp/.ssh/termui_ed25519"),
-		wish.WithPublicKeyAuth(func(ctx ssh.Context, key ssh.PublicKey) bool {
-			return true
-		}),
-		wish.WithKeyboardInteractiveAuth(func(ctx ssh.Context, challenger gossh.KeyboardInteractiveChallenge) bool {
-			return true
-		}),
+		withHostKeys(hostKeys),
+		wish.WithPublicKeyAuth(publicKeyHandler),
+		wish.WithKeyboardInteractiveAuth(keyboardInteractiveHandler),
 		wish.WithMiddleware(
 			radarBubbleteaMiddleware(),
 			activeterm.Middleware(),
+			execMiddleware(),
+			limitMiddleware(),
 			logging.Middleware(),
 		),
 	)
@@ -546,6 +808,27 @@ func main() {
 		}
 	}()
 
+	var streamServer *http.Server
+	if streamAddr != "" {
+		streamServer = newStreamServer(streamAddr)
+		log.Print("Starting stream server", "addr", streamAddr)
+		go func() {
+			if err := streamServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Print("Could not start stream server", "error", err)
+				done <- nil
+			}
+		}()
+	}
+
+	// SIGHUP reloads everything but the listeners and data files
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			reloadConfig(args)
+		}
+	}()
+
 	<-done
 	log.Println("Stopping SSH server")
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -553,6 +836,11 @@ func main() {
 	if err := s.Shutdown(ctx); err != nil && !errors.Is(err, ssh.ErrServerClosed) {
 		log.Print("Could not stop server", "error", err)
 	}
+	if streamServer != nil {
+		if err := streamServer.Shutdown(ctx); err != nil {
+			log.Print("Could not stop stream server", "error", err)
+		}
+	}
 }
 
 func radarBubbleteaMiddleware() wish.Middleware {
@@ -565,9 +853,27 @@ func radarBubbleteaMiddleware() wish.Middleware {
 			return nil
 		}
 
-		m := newModel()
+		m := newSessionModel(s)
+		m.setRenderer(bubbletea.MakeRenderer(s))
 		m.width = pty.Window.Width
 		m.height = pty.Window.Height
+		m.idleTimeout = settings().Limits.IdleTimeout
+		s.Context().SetValue(sessionModelKey{}, m)
+
+		// Command arguments and LC_WPIT_* variables override the saved profile
+		opts := &sessionOptions{}
+		err := opts.parse(opts.flagSet("whatplaneisthat", s.Stderr()), s.Command(), s.Environ())
+		if err == nil {
+			err = opts.apply(m)
+		}
+		if errors.Is(err, flag.ErrHelp) {
+			_ = s.Exit(0)
+			return nil
+		}
+		if err != nil {
+			wish.Fatalln(s, err)
+			return nil
+		}
 
 		p := tea.NewProgram(
 			m,
@@ -577,5 +883,5 @@ func radarBubbleteaMiddleware() wish.Middleware {
 		)
 		return p
 	}
-	return bubbletea.MiddlewareWithProgramHandler(teaHandler, termenv.TrueColor)
+	return bubbletea.MiddlewareWithProgramHandler(teaHandler, termenv.Ascii)
 }
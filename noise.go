@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+type noiseClass int
+
+const (
+	noiseLight noiseClass = iota
+	noiseHelicopter
+	noiseTurboprop
+	noiseNarrowbody
+	noiseHeavy
+)
+
+const (
+	// noiseReferenceFeet is the slant range the reference levels are given at
+	noiseReferenceFeet = 1000.0
+	// noiseAbsorptionPer1000Ft approximates atmospheric absorption of A-weighted jet noise
+	noiseAbsorptionPer1000Ft = 1.0
+	// noiseFloor is the level below which an aircraft is assumed inaudible over background noise
+	noiseFloor = 35.0
+	// phaseRateFPM is the vertical rate separating climb and descent from level flight
+	phaseRateFPM = 500.0
+)
+
+var noiseClassNames = map[noiseClass]string{
+	noiseLight:      "light",
+	noiseHelicopter: "helicopter",
+	noiseTurboprop:  "turboprop",
+	noiseNarrowbody: "narrowbody",
+	noiseHeavy:      "heavy jet",
+}
+
+// noiseReferenceLevels are typical dB(A) levels at noiseReferenceFeet in level flight
+var noiseReferenceLevels = map[noiseClass]float64{
+	noiseLight:      72,
+	noiseHelicopter: 84,
+	noiseTurboprop:  80,
+	noiseNarrowbody: 86,
+	noiseHeavy:      92,
+}
+
+// phaseAdjustments account for climb power being louder than approach and level flight
+var phaseAdjustments = map[string]float64{
+	"climb":   4,
+	"level":   0,
+	"descent": -2,
+}
+
+// aircraftNoiseClasses maps ICAO type designators that can't be told apart by ADS-B
+// emitter category alone
+var aircraftNoiseClasses = map[string]noiseClass{
+	// Turboprops
+	"AT43": noiseTurboprop, "AT45": noiseTurboprop, "AT72": noiseTurboprop, "AT75": noiseTurboprop,
+	"AT76": noiseTurboprop, "DH8A": noiseTurboprop, "DH8B": noiseTurboprop, "DH8C": noiseTurboprop,
+	"DH8D": noiseTurboprop, "SF34": noiseTurboprop, "SB20": noiseTurboprop, "JS41": noiseTurboprop,
+	"B190": noiseTurboprop, "C208": noiseTurboprop, "PC12": noiseTurboprop, "BE20": noiseTurboprop,
+	"B350": noiseTurboprop, "D328": noiseTurboprop, "F50": noiseTurboprop, "L410": noiseTurboprop,
+	"DHC6": noiseTurboprop, "C130": noiseTurboprop, "A400": noiseTurboprop,
+	// Helicopters
+	"EC35": noiseHelicopter, "EC45": noiseHelicopter, "EC30": noiseHelicopter, "EC55": noiseHelicopter,
+	"EC75": noiseHelicopter, "A109": noiseHelicopter, "A139": noiseHelicopter, "A169": noiseHelicopter,
+	"A189": noiseHelicopter, "AS50": noiseHelicopter, "AS55": noiseHelicopter, "AS65": noiseHelicopter,
+	"S76": noiseHelicopter, "S92": noiseHelicopter, "R44": noiseHelicopter, "R22": noiseHelicopter,
+	"B06": noiseHelicopter, "B407": noiseHelicopter, "B429": noiseHelicopter, "H60": noiseHelicopter,
+	"EH10": noiseHelicopter, "CH47": noiseHelicopter,
+	// Wide bodies
+	"A332": noiseHeavy, "A333": noiseHeavy, "A338": noiseHeavy, "A339": noiseHeavy, "A343": noiseHeavy,
+	"A346": noiseHeavy, "A359": noiseHeavy, "A35K": noiseHeavy, "A388": noiseHeavy, "B744": noiseHeavy,
+	"B748": noiseHeavy, "B762": noiseHeavy, "B763": noiseHeavy, "B764": noiseHeavy, "B772": noiseHeavy,
+	"B773": noiseHeavy, "B77L": noiseHeavy, "B77W": noiseHeavy, "B788": noiseHeavy, "B789": noiseHeavy,
+	"B78X": noiseHeavy, "MD11": noiseHeavy, "A124": noiseHeavy, "C17": noiseHeavy,
+}
+
+// classifyNoise picks a noise class from the aircraft type, preferring an imported aircraft
+// dataset, falling back to its ADS-B emitter category
+func classifyNoise(p plane) noiseClass {
+	if class, ok := reference.noiseClass(p.AircraftType); ok {
+		return class
+	}
+	if class, ok := aircraftNoiseClasses[strings.ToUpper(strings.TrimSpace(p.AircraftType))]; ok {
+		return class
+	}
+	switch p.Category {
+	case "A1", "B1", "B4":
+		return noiseLight
+	case "A2":
+		return noiseTurboprop
+	case "A5":
+		return noiseHeavy
+	case "A7":
+		return noiseHelicopter
+	}
+	return noiseNarrowbody
+}
+
+// flightPhase describes whether p is climbing, descending or in level flight
+func flightPhase(p plane) string {
+	switch {
+	case p.BaroRate > phaseRateFPM:
+		return "climb"
+	case p.BaroRate < -phaseRateFPM:
+		return "descent"
+	}
+	return "level"
+}
+
+// estimateNoise estimates the dB(A) level at the observer from p at slantNM nautical miles,
+// using spherical spreading and atmospheric absorption from the class reference level
+func estimateNoise(p plane, slantNM float64) (float64, bool) {
+	altitude, ok := p.Altitude()
+	if !ok || p.AltBaro.Ground {
+		return 0, false
+	}
+
+	slantFeet := math.Max(slantNM*feetPerNM, noiseReferenceFeet/4)
+	level := noiseReferenceLevels[classifyNoise(p)] + phaseAdjustments[flightPhase(p)]
+	level -= 20 * math.Log10(slantFeet/noiseReferenceFeet)
+	level -= noiseAbsorptionPer1000Ft * (slantFeet - noiseReferenceFeet) / 1000
+
+	// Engines are throttled back well above the climb out
+	if altitude > 20000 {
+		level -= 3
+	}
+	return level, level >= noiseFloor
+}
+
+func formatNoise(p plane) string {
+	if !p.Audible {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f", p.NoiseLevel)
+}
+
+// loudestNow returns the plane with the highest estimated level at the observer
+func (m *model) loudestNow() (plane, bool) {
+	var loudest plane
+	found := false
+	for _, p := range m.planes {
+		if !p.Audible {
+			continue
+		}
+		if !found || p.NoiseLevel > loudest.NoiseLevel {
+			loudest = p
+			found = true
+		}
+	}
+	return loudest, found
+}
+
+func (m *model) loudestStatus() string {
+	p, ok := m.loudestNow()
+	if !ok {
+		return "loudest: none audible"
+	}
+	name := strings.TrimSpace(p.FlightCode)
+	if name == "" {
+		name = p.Hex
+	}
+	return fmt.Sprintf("loudest: %s ~%.0f dB(A) %s", name, p.NoiseLevel, noiseClassNames[classifyNoise(p)])
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const profilesSchema = `
+CREATE TABLE IF NOT EXISTS profiles (
+	key_fingerprint TEXT PRIMARY KEY,
+	settings        TEXT NOT NULL,
+	updated_at      TEXT NOT NULL
+);
+`
+
+// profile is the per user settings restored when an SSH public key reconnects. It is
+// stored as JSON so settings can be added without migrating the table.
+type profile struct {
+	Lat               float64 `json:"lat"`
+	Lon               float64 `json:"lon"`
+	ObserverElevation float64 `json:"observer_elevation_ft"`
+	RadarRange        int     `json:"range_nm"`
+	NorthOffset       float64 `json:"north_offset"`
+	AcousticMode      bool    `json:"acoustic_mode"`
+	ViewingSectors    string  `json:"viewing_sectors"`
+	Theme             string  `json:"theme,omitempty"`
+	Renderer          string  `json:"renderer,omitempty"`
+}
+
+// profileStore persists profiles keyed by the SHA256 fingerprint of the user's public key
+type profileStore struct {
+	db *sql.DB
+}
+
+// profiles is the server wide profile database, nil when profiles are disabled
+var profiles *profileStore
+
+func openProfileStore(path string) (*profileStore, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(profilesSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &profileStore{db: db}, nil
+}
+
+func (s *profileStore) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+func (s *profileStore) Load(fingerprint string) (profile, bool) {
+	var p profile
+	if s == nil || fingerprint == "" {
+		return p, false
+	}
+
+	var settings string
+	err := s.db.QueryRow(`SELECT settings FROM profiles WHERE key_fingerprint = ?`, fingerprint).Scan(&settings)
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, false
+	}
+	if err == nil {
+		err = json.Unmarshal([]byte(settings), &p)
+	}
+	if err != nil {
+		log.Printf("Could not load profile for %s: %v", fingerprint, err)
+		return p, false
+	}
+	return p, true
+}
+
+func (s *profileStore) Save(fingerprint string, p profile) error {
+	if s == nil {
+		return errors.New("profiles are disabled on this server")
+	}
+	if fingerprint == "" {
+		return errors.New("profiles need a public key login")
+	}
+
+	settings, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO profiles (key_fingerprint, settings, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (key_fingerprint) DO UPDATE SET settings = excluded.settings, updated_at = excluded.updated_at`,
+		fingerprint, string(settings), time.Now().UTC().Format(sightingTimeFormat))
+	return err
+}
+
+func (m *model) profile() profile {
+	return profile{
+		Lat:               m.lat,
+		Lon:               m.lon,
+		ObserverElevation: m.observerElevation,
+		RadarRange:        m.radarRange,
+		NorthOffset:       m.northOffset,
+		AcousticMode:      m.acousticMode,
+		ViewingSectors:    formatViewingSectors(m.viewingSectors),
+		Theme:             m.themeName,
+		Renderer:          m.radarRenderer,
+	}
+}
+
+func (m *model) applyProfile(p profile) {
+	if !m.locationLocked {
+		m.lat = p.Lat
+		m.lon = p.Lon
+		m.observerElevation = p.ObserverElevation
+	}
+	if p.RadarRange >= MIN_RADAR_RANGE && p.RadarRange <= m.maxRadarRange {
+		m.radarRange = p.RadarRange
+	}
+	m.northOffset = p.NorthOffset
+	m.acousticMode = p.AcousticMode
+	if sectors, err := parseViewingSectors(p.ViewingSectors); err == nil {
+		m.viewingSectors = sectors
+	}
+	// A theme removed from the config since the profile was saved is ignored
+	if p.Theme != "" {
+		m.setTheme(p.Theme)
+	}
+	if r, err := parseRadarRenderer(p.Renderer); err == nil {
+		m.radarRenderer = r
+	}
+}
+
+func (m *model) saveProfile() {
+	if m.guest {
+		m.statusMessage = "Guests can't save a profile"
+		return
+	}
+	if err := profiles.Save(m.profileKey, m.profile()); err != nil {
+		m.statusMessage = "Could not save profile: " + err.Error()
+		return
+	}
+	m.statusMessage = "Profile saved"
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runQuery prints the aircraft near a location once and exits, like "ssh host nearby"
+// without a server
+func runQuery(args []string) {
+	opts := &sessionOptions{}
+	fs := opts.flagSet("query", os.Stderr)
+	configPath := addConfigFlag(fs)
+	overhead := fs.Bool("overhead", false, "Only list aircraft overhead or due to pass overhead, soonest first")
+	resolveFormat := formatFlags(fs)
+	err := opts.parse(fs, args, os.Environ())
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	format, formatErr := resolveFormat()
+	if err == nil {
+		err = formatErr
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if _, err := useConfigFile(*configPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	m := newModel()
+	if err := opts.apply(m); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	command := execCommands["nearby"]
+	if *overhead {
+		command = execCommands["overhead"]
+	}
+	if err := writeAircraftList(os.Stdout, m, command, format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
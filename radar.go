@@ -15,6 +15,13 @@ type radarContext struct {
 	height int
 	maxR   float64
 	r      float64
+	cells  [][]cell
+}
+
+// radarScale is how many characters from the centre each NM is drawn. Anything at the full
+// range sits a few characters inside the bezel.
+func (m *model) radarScale(ctx radarContext) float64 {
+	return float64(ctx.maxR-4) / float64(m.radarRange)
 }
 
 func inBounds(width int, height int, x int, y int) bool {
@@ -42,21 +49,27 @@ func getPlaneSymbol(p plane) rune {
 	return '*'
 }
 
-func (m *model) renderDistanceLabels(ctx radarContext) {
-	charsPerNM := float64(ctx.r) / float64(m.radarRange)
+// rangeRingStep picks the distance between range rings, a "nice" number of NM that keeps
+// them, and their labels, at least three characters apart
+func (m *model) rangeRingStep(ctx radarContext) float64 {
+	charsPerNM := m.radarScale(ctx)
 	minLabelSpacingPx := 3.0
-	maxLabels := int(float64(ctx.r) / (minLabelSpacingPx * charsPerNM))
+	maxLabels := int(float64(m.radarRange) * charsPerNM / minLabelSpacingPx)
 	if maxLabels < 1 {
 		maxLabels = 1
 	}
 	niceSteps := []float64{1, 5, 10, 15, 20, 25, 50, 100, 200, 500}
-	labelStepNM := niceSteps[len(niceSteps)-1]
 	for _, step := range niceSteps {
 		if float64(m.radarRange)/step <= float64(maxLabels) {
-			labelStepNM = step
-			break
+			return step
 		}
 	}
+	return niceSteps[len(niceSteps)-1]
+}
+
+func (m *model) renderDistanceLabels(ctx radarContext) {
+	charsPerNM := m.radarScale(ctx)
+	labelStepNM := m.rangeRingStep(ctx)
 	for d := labelStepNM; d < float64(m.radarRange); d += labelStepNM {
 		radius := d * charsPerNM
 		x := ctx.cx + int(radius*math.Sin(0))
@@ -64,7 +77,7 @@ func (m *model) renderDistanceLabels(ctx radarContext) {
 		label := strconv.Itoa(int(d))
 		for i, r := range []rune(label) {
 			if inBounds(ctx.width, ctx.height, x+i, y) {
-				c := &m.buffer[y][x+i]
+				c := &ctx.cells[y][x+i]
 				c.kind = "label"
 				c.char = r
 			}
@@ -72,6 +85,58 @@ func (m *model) renderDistanceLabels(ctx radarContext) {
 	}
 }
 
+// plotCircle calls mark for each cell a circle around the centre passes through, skipping
+// planes, which stay in the buffer between frames
+func (m *model) plotCircle(ctx radarContext, radius float64, mark func(c *cell)) {
+	steps := int(4*math.Pi*radius) + 1
+	for i := 0; i < steps; i++ {
+		theta := 2 * math.Pi * float64(i) / float64(steps)
+		x := ctx.cx + int(radius*math.Sin(theta))
+		y := ctx.cy - int(radius*math.Cos(theta)*m.aspectRatio)
+		if inBounds(ctx.width, ctx.height, x, y) && ctx.cells[y][x].kind != "plane" {
+			mark(&ctx.cells[y][x])
+		}
+	}
+}
+
+// renderRangeRings draws a dotted ring at each labelled distance
+func (m *model) renderRangeRings(ctx radarContext) {
+	charsPerNM := m.radarScale(ctx)
+	step := m.rangeRingStep(ctx)
+	for d := step; d < float64(m.radarRange); d += step {
+		m.plotCircle(ctx, d*charsPerNM, func(c *cell) {
+			c.kind = "ring"
+			c.char = '·'
+		})
+	}
+}
+
+// bezelTick is the mark on the bezel at a bearing in degrees, longer every 30°
+func bezelTick(degrees int) rune {
+	if degrees%30 == 0 {
+		return '+'
+	}
+	return '·'
+}
+
+// renderBezel draws the edge of the scope with a tick every 10°, turning with the north offset
+func (m *model) renderBezel(ctx radarContext) {
+	m.plotCircle(ctx, ctx.r, func(c *cell) {
+		c.kind = "bezel"
+		c.char = ' '
+	})
+	for deg := 0; deg < 360; deg += 10 {
+		phi := float64(deg)*math.Pi/180 - m.northOffset
+		x := ctx.cx + int(ctx.r*math.Sin(phi))
+		y := ctx.cy - int(ctx.r*math.Cos(phi)*m.aspectRatio)
+		if inBounds(ctx.width, ctx.height, x, y) && ctx.cells[y][x].kind != "plane" {
+			c := &ctx.cells[y][x]
+			c.kind = "bezel"
+			c.char = bezelTick(deg)
+		}
+	}
+}
+
 func (m *model) renderSweepArm(ctx radarContext) {
 	prevAngle := m.sweepAngle - 0.15
 	for l := 0; l <= int(ctx.r); l++ {
@@ -79,8 +144,8 @@ func (m *model) renderSweepArm(ctx radarContext) {
 			theta := prevAngle + interp*(m.sweepAngle-prevAngle)
 			x := ctx.cx + int(float64(l)*math.Sin(theta))
 			y := ctx.cy - int(float64(l)*math.Cos(theta)*m.aspectRatio)
-			if inBounds(len(m.buffer[0]), len(m.buffer), x, y) {
-				c := &m.buffer[y][x]
+			if inBounds(len(ctx.cells[0]), len(ctx.cells), x, y) {
+				c := &ctx.cells[y][x]
 				c.kind = "sweep"
 				c.sweepAge = int(interp * 10)
 				c.char = ' '
@@ -95,39 +160,41 @@ func (m *model) renderPlanes(ctx radarContext) {
 			if p.DistanceFromObserver > float64(m.radarRange) {
 				continue
 			}
-			scale := float64(ctx.maxR-4) / float64(m.radarRange)
-			virtualDistance := p.DistanceFromObserver * scale
+			virtualDistance := p.DistanceFromObserver * m.radarScale(ctx)
 			displayBearing := p.BearingFromObserver - m.northOffset
 			posX := ctx.cx + int(virtualDistance*math.Sin(displayBearing))
 			posY := ctx.cy - int(virtualDistance*math.Cos(displayBearing)*m.aspectRatio)
 			dx := float64(posX - ctx.cx)
 			dy := float64(posY - ctx.cy)
 			if inBounds(ctx.width, ctx.height, posX, posY) && math.Sqrt(dx*dx+dy*dy) < ctx.r {
-				c := &m.buffer[posY][posX]
+				c := &ctx.cells[posY][posX]
 				c.kind = "plane"
 				c.char = getPlaneSymbol(p)
 				c.sweepAge = 0
+				c.inSector = p.InSector
 			}
 		}
 	}
 }
 
+// renderBearingLabels puts the cardinal and intercardinal letters outside the bezel
 func (m *model) renderBearingLabels(ctx radarContext) {
-	tickMarks := []float64{0, 90, 180, 270}
-	tickLabels := [][]rune{
-		{'0'},
-		{'9', '0'},
-		{'1', '8', '0'},
-		{'2', '7', '0'},
-	}
-	for i, tick := range tickMarks {
-		phi := tick * math.Pi / 180
-		phi -= m.northOffset
+	for i, point := range compassPoints {
+		phi := float64(i)*math.Pi/4 - m.northOffset
 		x := ctx.cx + int((ctx.r+3)*math.Sin(phi))
 		y := ctx.cy - int((ctx.r+3)*math.Cos(phi)*m.aspectRatio)
-		for j, r := range tickLabels[i] {
+		// Letters on the right start at the bezel and ones on the left end there, so they
+		// never run into it, with those at the top and bottom centred
+		label := []rune(point)
+		switch sin := math.Sin(phi); {
+		case sin < -0.3:
+			x -= len(label) - 1
+		case sin <= 0.3:
+			x -= (len(label) - 1) / 2
+		}
+		for j, r := range label {
 			if inBounds(ctx.width, ctx.height, x+j, y) {
-				c := &m.buffer[y][x+j]
+				c := &ctx.cells[y][x+j]
 				c.char = r
 				c.kind = "label"
 			}
@@ -167,53 +234,128 @@ func (m *model) renderRadar(width, height int) string {
 		height: height,
 		maxR:   maxR,
 		r:      r,
+		cells:  m.buffer,
+	}
+	if m.radarRenderer != rendererCells {
+		return m.renderCanvas(ctx)
 	}
 
+	m.renderViewingSectors(ctx)
+	m.renderRangeRings(ctx)
 	m.renderDistanceLabels(ctx)
 	m.renderSweepArm(ctx)
+	m.renderBezel(ctx)
 	m.renderPlanes(ctx)
+	if m.acousticMode {
+		m.renderHeardPosition(ctx)
+	}
 	m.renderBearingLabels(ctx)
 
+	t := m.theme
+	bright, medium, dim, dimmest := lipgloss.Color(t.Bright), lipgloss.Color(t.Medium), lipgloss.Color(t.Dim), lipgloss.Color(t.Dimmest)
+	sector := lipgloss.Color(t.Sector)
+	frame := m.renderer.NewStyle().Background(lipgloss.Color(t.Frame))
+	heard := m.renderer.NewStyle().Bold(true).Foreground(lipgloss.Color(t.Heard))
+
+	limited := m.limitedColors()
+
 	var b strings.Builder
-	for _, row := range m.buffer {
+	for y, row := range m.buffer {
+		// No newline after the last row, so the status bar doesn't push the top off screen
+		if y > 0 {
+			b.WriteByte('\n')
+		}
 		for _, c := range row {
-			if c.kind == "ring" {
-				b.WriteString(frameBg.Render(" "))
+			if c.kind == "bezel" {
+				char := c.char
+				// Without colour the bezel has no background to show it, so it's dotted
+				if char == ' ' && t.Frame == "" {
+					char = '·'
+				}
+				b.WriteString(frame.Foreground(medium).Render(string(char)))
+				continue
+			}
+			if c.kind == "heard" {
+				b.WriteString(heard.Render(string(c.char)))
 				continue
 			}
-			style := lipgloss.NewStyle()
+			if limited {
+				b.WriteString(m.renderLimitedCell(c))
+				continue
+			}
+			style := m.renderer.NewStyle()
 			// Color fades based on how long ago it was sweeped
 			switch {
 			case c.sweepAge <= 2:
-				style = style.Background(brightGreen)
+				style = style.Background(bright)
 			case c.sweepAge > 2 && c.sweepAge <= 7:
-				style = style.Background(mediumGreen)
+				style = style.Background(medium)
 			case c.sweepAge > 3 && c.sweepAge <= 12:
-				style = style.Background(dimGreen)
+				style = style.Background(dim)
+			case c.kind == "sector":
+				style = style.Background(sector)
+			}
+			if c.kind == "ring" {
+				style = style.Foreground(lipgloss.Color(t.Frame))
 			}
 			// Color the plane icons based on how long ago it was sweeped. Takes longer to fade than the background.
 			if c.kind == "plane" {
+				// Planes that can be seen from the observer's viewing sectors stand out
+				if c.inSector {
+					style = style.Bold(true).Underline(true)
+				}
 				switch {
 				case c.sweepAge <= 15:
-					style = style.Foreground(brightGreen)
+					style = style.Foreground(bright)
 				case c.sweepAge > 15 && c.sweepAge <= 30:
-					style = style.Foreground(mediumGreen)
+					style = style.Foreground(medium)
 				case c.sweepAge > 30 && c.sweepAge <= 60:
-					style = style.Foreground(dimGreen)
+					style = style.Foreground(dim)
 				case c.sweepAge > 60 && c.sweepAge <= 90:
-					style = style.Foreground(dimmestGreen)
+					style = style.Foreground(dimmest)
 				case c.sweepAge == 99:
 					c.kind = "blank"
 					c.char = ' '
 				default:
-					style = style.Foreground(dimGreen)
+					style = style.Foreground(dim)
 				}
 				b.WriteString(style.Render(string(c.char)))
 				continue
 			}
 			b.WriteString(style.Render(string(c.char)))
 		}
-		b.WriteByte('\n')
 	}
 	return b.String()
 }
+
+// renderLimitedCell draws a cell for terminals with 16 colours or none, where the fade is
+// drawn with shading characters and planes age from bold to faint
+func (m *model) renderLimitedCell(c cell) string {
+	style := m.renderer.NewStyle()
+	char := c.char
+	switch {
+	case c.kind == "plane":
+		if c.inSector {
+			style = style.Underline(true)
+		}
+		switch {
+		case c.sweepAge <= 15:
+			style = style.Bold(true).Foreground(lipgloss.Color(m.theme.Bright))
+		case c.sweepAge <= 60:
+			style = style.Foreground(lipgloss.Color(m.theme.Medium))
+		case c.sweepAge == 99:
+			char = ' '
+		default:
+			style = style.Faint(true).Foreground(lipgloss.Color(m.theme.Dim))
+		}
+	case char == ' ' && c.sweepAge <= 12:
+		char = fadeShade(c.sweepAge)
+		style = style.Foreground(lipgloss.Color(m.theme.Bright))
+	case c.kind == "ring":
+		style = style.Faint(true)
+	case char == ' ' && c.kind == "sector":
+		char = '·'
+		style = style.Faint(true)
+	}
+	return style.Render(string(char))
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// viewingSector is a part of the sky the observer can actually see, e.g. out of a window.
+// Azimuths run clockwise from fromAz to toAz in degrees and may wrap through north.
+type viewingSector struct {
+	fromAz float64
+	toAz   float64
+	minEl  float64
+	maxEl  float64
+}
+
+// parseViewingSectors parses a comma separated list of sectors in the form
+// FROM-TO[@MINEL[-MAXEL]], e.g. "120-210@15, 300-20"
+func parseViewingSectors(s string) ([]viewingSector, error) {
+	var sectors []viewingSector
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		sector := viewingSector{maxEl: 90}
+		azPart, elPart, hasEl := strings.Cut(part, "@")
+
+		from, to, ok := strings.Cut(azPart, "-")
+		if !ok {
+			return nil, fmt.Errorf("sector %q needs an azimuth range like 120-210", part)
+		}
+		var err error
+		if sector.fromAz, err = parseAngle(from, 0, 360); err != nil {
+			return nil, fmt.Errorf("sector %q: %v", part, err)
+		}
+		if sector.toAz, err = parseAngle(to, 0, 360); err != nil {
+			return nil, fmt.Errorf("sector %q: %v", part, err)
+		}
+
+		if hasEl {
+			minEl, maxEl, hasMax := strings.Cut(elPart, "-")
+			if sector.minEl, err = parseAngle(minEl, -90, 90); err != nil {
+				return nil, fmt.Errorf("sector %q: %v", part, err)
+			}
+			if hasMax {
+				if sector.maxEl, err = parseAngle(maxEl, -90, 90); err != nil {
+					return nil, fmt.Errorf("sector %q: %v", part, err)
+				}
+			}
+			if sector.minEl > sector.maxEl {
+				return nil, fmt.Errorf("sector %q: minimum elevation is above maximum", part)
+			}
+		}
+		sectors = append(sectors, sector)
+	}
+	return sectors, nil
+}
+
+func parseAngle(s string, lo float64, hi float64) (float64, error) {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "°")
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid angle %q", s)
+	}
+	if v < lo || v > hi {
+		return 0, fmt.Errorf("angle %g must be between %g and %g", v, lo, hi)
+	}
+	return v, nil
+}
+
+func (v viewingSector) String() string {
+	s := fmt.Sprintf("%g-%g", v.fromAz, v.toAz)
+	switch {
+	case v.maxEl < 90:
+		s += fmt.Sprintf("@%g-%g", v.minEl, v.maxEl)
+	case v.minEl != 0:
+		s += fmt.Sprintf("@%g", v.minEl)
+	}
+	return s
+}
+
+func formatViewingSectors(sectors []viewingSector) string {
+	parts := make([]string, len(sectors))
+	for i, v := range sectors {
+		parts[i] = v.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// containsAzimuth reports whether bearing (radians) falls within the sector
+func (v viewingSector) containsAzimuth(bearing float64) bool {
+	// 0-360 would otherwise wrap round to 0-0
+	if v.toAz-v.fromAz >= 360 {
+		return true
+	}
+	deg := math.Mod(bearing*180/math.Pi+360, 360)
+	from := math.Mod(v.fromAz, 360)
+	to := math.Mod(v.toAz, 360)
+	if from <= to {
+		return deg >= from && deg <= to
+	}
+	return deg >= from || deg <= to
+}
+
+// contains reports whether p can be seen through the sector. Planes without an altitude
+// can only be seen through sectors that reach down to the horizon.
+func (v viewingSector) contains(p plane) bool {
+	if !v.containsAzimuth(p.BearingFromObserver) {
+		return false
+	}
+	if !p.HasAltitude {
+		return v.minEl <= 0
+	}
+	return p.ElevationAngle >= v.minEl && p.ElevationAngle <= v.maxEl
+}
+
+// inViewingSector reports whether p is visible through any of the observer's sectors
+func (m *model) inViewingSector(p plane) bool {
+	for _, v := range m.viewingSectors {
+		if v.contains(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatInSector(p plane) string {
+	if p.InSector {
+		return "●"
+	}
+	return ""
+}
+
+// renderViewingSectors shades the cells of the radar within a viewing sector's azimuths
+func (m *model) renderViewingSectors(ctx radarContext) {
+	if len(m.viewingSectors) == 0 {
+		return
+	}
+	for y := range ctx.cells {
+		for x := range ctx.cells[y] {
+			dx := float64(x - ctx.cx)
+			dy := float64(ctx.cy-y) / m.aspectRatio
+			if math.Hypot(dx, dy) > ctx.r {
+				continue
+			}
+			bearing := math.Atan2(dx, dy) + m.northOffset
+			for _, v := range m.viewingSectors {
+				if v.containsAzimuth(bearing) {
+					c := &ctx.cells[y][x]
+					if c.kind == "blank" {
+						c.kind = "sector"
+					}
+					break
+				}
+			}
+		}
+	}
+}
+
+func (m *model) openSectorModal() {
+	m.showSectorModal = true
+	m.sectorErr = ""
+	m.sectorInput.SetValue(formatViewingSectors(m.viewingSectors))
+	m.sectorInput.Focus()
+}
+
+func (m *model) handleSectorInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		sectors, err := parseViewingSectors(m.sectorInput.Value())
+		if err != nil {
+			m.sectorErr = err.Error()
+			return m, nil
+		}
+		m.viewingSectors = sectors
+		for i := range m.planes {
+			m.planes[i].InSector = m.inViewingSector(m.planes[i])
+		}
+		m.refreshTableRows()
+		m.showSectorModal = false
+		m.sectorInput.Blur()
+		return m, nil
+	case "esc":
+		m.showSectorModal = false
+		m.sectorInput.Blur()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.sectorInput, cmd = m.sectorInput.Update(msg)
+	return m, cmd
+}
+
+func (m *model) renderSectorModal() string {
+	errLine := ""
+	if m.sectorErr != "" {
+		errLine = m.renderer.NewStyle().Foreground(lipgloss.Color(m.theme.Error)).Render(m.sectorErr)
+	}
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		m.renderer.NewStyle().Bold(true).Render("Set Viewing Sectors"),
+		"",
+		"Azimuth FROM-TO, optionally @MINEL[-MAXEL]",
+		m.renderer.NewStyle().Faint(true).Render("e.g. 120-210@15, 300-20"),
+		"",
+		m.sectorInput.View(),
+		errLine,
+		"",
+		m.renderer.NewStyle().Faint(true).Render("Enter: Apply | Esc: Cancel | Empty: Clear"),
+	)
+
+	return m.renderer.NewStyle().
+		Border(lipgloss.NormalBorder()).
+		Padding(1, 2).
+		Background(lipgloss.Color("#222")).
+		Foreground(lipgloss.Color("#fff")).
+		Align(lipgloss.Center, lipgloss.Center).
+		Width(50).
+		Height(13).
+		Render(content)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestParseViewingSectors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []viewingSector
+		err  bool
+	}{
+		{in: "", want: nil},
+		{in: "120-210", want: []viewingSector{{fromAz: 120, toAz: 210, maxEl: 90}}},
+		{in: "120-210@15", want: []viewingSector{{fromAz: 120, toAz: 210, minEl: 15, maxEl: 90}}},
+		{in: "120°-210°@15-60", want: []viewingSector{{fromAz: 120, toAz: 210, minEl: 15, maxEl: 60}}},
+		{in: "120-210@15, 300-20", want: []viewingSector{
+			{fromAz: 120, toAz: 210, minEl: 15, maxEl: 90},
+			{fromAz: 300, toAz: 20, maxEl: 90},
+		}},
+		{in: " 0-360 ,", want: []viewingSector{{fromAz: 0, toAz: 360, maxEl: 90}}},
+
+		{in: "120", err: true},
+		{in: "120-", err: true},
+		{in: "abc-210", err: true},
+		{in: "120-361", err: true},
+		{in: "120-210@91", err: true},
+		{in: "120-210@60-15", err: true},
+		{in: "120-210, 300", err: true},
+	}
+	for _, tt := range tests {
+		got, err := parseViewingSectors(tt.in)
+		if tt.err {
+			if err == nil {
+				t.Errorf("parseViewingSectors(%q) = %v, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseViewingSectors(%q) failed: %v", tt.in, err)
+		} else if !slices.Equal(got, tt.want) {
+			t.Errorf("parseViewingSectors(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormatViewingSectors(t *testing.T) {
+	for _, s := range []string{"120-210", "120-210@15", "120-210@15-60", "120-210@0-60, 300-20"} {
+		sectors, err := parseViewingSectors(s)
+		if err != nil {
+			t.Fatalf("parseViewingSectors(%q) failed: %v", s, err)
+		}
+		if got := formatViewingSectors(sectors); got != s {
+			t.Errorf("formatViewingSectors(parseViewingSectors(%q)) = %q", s, got)
+		}
+	}
+}
+
+func TestViewingSectorContains(t *testing.T) {
+	deg := math.Pi / 180
+	tests := []struct {
+		sector string
+		p      plane
+		want   bool
+	}{
+		{"120-210", plane{BearingFromObserver: 150 * deg}, true},
+		{"120-210", plane{BearingFromObserver: 100 * deg}, false},
+		{"120-210", plane{BearingFromObserver: 210 * deg}, true},
+		// Sectors can wrap through north
+		{"300-20", plane{BearingFromObserver: 350 * deg}, true},
+		{"300-20", plane{BearingFromObserver: 10 * deg}, true},
+		{"300-20", plane{BearingFromObserver: -10 * deg}, true},
+		{"300-20", plane{BearingFromObserver: 180 * deg}, false},
+		{"0-360", plane{BearingFromObserver: 180 * deg}, true},
+		{"0-0", plane{BearingFromObserver: 180 * deg}, false},
+		{"120-210@15-60", plane{BearingFromObserver: 150 * deg, HasAltitude: true, ElevationAngle: 30}, true},
+		{"120-210@15-60", plane{BearingFromObserver: 150 * deg, HasAltitude: true, ElevationAngle: 10}, false},
+		{"120-210@15-60", plane{BearingFromObserver: 150 * deg, HasAltitude: true, ElevationAngle: 70}, false},
+		// Without an altitude only sectors down to the horizon count
+		{"120-210", plane{BearingFromObserver: 150 * deg}, true},
+		{"120-210@15", plane{BearingFromObserver: 150 * deg}, false},
+	}
+	for _, tt := range tests {
+		sectors, err := parseViewingSectors(tt.sector)
+		if err != nil {
+			t.Fatalf("parseViewingSectors(%q) failed: %v", tt.sector, err)
+		}
+		if got := sectors[0].contains(tt.p); got != tt.want {
+			t.Errorf("%s contains bearing %.0f° elevation %.0f° = %v, want %v",
+				tt.sector, tt.p.BearingFromObserver/deg, tt.p.ElevationAngle, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// sessionEnvPrefix is the prefix of environment variables configuring a session. OpenSSH
+// clients forward LC_* variables by default, e.g. LC_WPIT_RANGE=25 sets --range.
+const sessionEnvPrefix = "LC_WPIT_"
+
+// sessionOptions are the settings an SSH client can pass as command arguments, e.g.
+// ssh host -- --lat 51.47 --lon -0.45 --range 25
+type sessionOptions struct {
+	lat        string
+	lon        string
+	location   string
+	elevation  string
+	radarRange string
+	north      string
+	sectors    string
+	acoustic   string
+	theme      string
+	colors     string
+	renderer   string
+}
+
+func (o *sessionOptions) flagSet(name string, output io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&o.lat, "lat", "", "Observer latitude, decimal or DMS")
+	fs.StringVar(&o.lon, "lon", "", "Observer longitude, decimal or DMS")
+	fs.StringVar(&o.location, "location", "", "Observer position as a grid square, geohash, plus code, \"lat, lon\" or a place or airport")
+	fs.StringVar(&o.elevation, "elevation", "", "Observer elevation in feet")
+	fs.StringVar(&o.radarRange, "range", "", fmt.Sprintf("Radar range in NM (%d-%d)", MIN_RADAR_RANGE, settings().Defaults.MaxRange))
+	fs.StringVar(&o.north, "north", "", "Bearing in degrees shown at the top of the radar")
+	fs.StringVar(&o.sectors, "sectors", "", "Viewing sectors, e.g. \"120-210@15, 300-20\"")
+	fs.StringVar(&o.acoustic, "acoustic", "", "Start in acoustic mode (true or false)")
+	fs.StringVar(&o.theme, "theme", "", "Colour theme: "+strings.Join(themeNames(), ", "))
+	fs.StringVar(&o.colors, "colors", "auto", "Colours the terminal supports: auto, truecolor, 256, 16 or none")
+	fs.StringVar(&o.renderer, "renderer", "", "Radar renderer: "+strings.Join(radarRenderers, ", "))
+	return fs
+}
+
+// parse reads LC_WPIT_* variables from environ and then the command arguments, which take
+// precedence. Variables for flags fs doesn't have are ignored, so settings only used by exec
+// commands don't break interactive sessions.
+func (o *sessionOptions) parse(fs *flag.FlagSet, args []string, environ []string) error {
+	for _, kv := range environ {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(key, sessionEnvPrefix) {
+			continue
+		}
+		name := strings.ToLower(strings.TrimPrefix(key, sessionEnvPrefix))
+		if fs.Lookup(name) == nil {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	return nil
+}
+
+// apply validates the options and sets them on the model
+func (o *sessionOptions) apply(m *model) error {
+	if m.locationLocked && (o.lat != "" || o.lon != "" || o.location != "" || o.elevation != "") {
+		return fmt.Errorf("guests can't change the location")
+	}
+
+	lat, lon, err := parseLocation(o.lat, o.lon, m.lat, m.lon)
+	if err != nil {
+		return err
+	}
+	if o.location != "" {
+		var ok bool
+		lat, lon, ok, err = parsePosition(o.location, m.lat, m.lon)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			matches := gazetteer.Search(o.location)
+			if len(matches) == 0 {
+				return fmt.Errorf("unknown location %q", o.location)
+			}
+			lat, lon = matches[0].lat, matches[0].lon
+			if matches[0].hasElevation {
+				m.observerElevation = matches[0].elevationFt
+			}
+		}
+	}
+	m.lat = lat
+	m.lon = lon
+
+	if o.elevation != "" {
+		if m.observerElevation, err = strconv.ParseFloat(o.elevation, 64); err != nil {
+			return fmt.Errorf("invalid elevation %q", o.elevation)
+		}
+	}
+	if o.radarRange != "" {
+		r, err := strconv.Atoi(o.radarRange)
+		if err != nil || r < MIN_RADAR_RANGE || r > m.maxRadarRange {
+			return fmt.Errorf("range must be a whole number of NM between %d and %d", MIN_RADAR_RANGE, m.maxRadarRange)
+		}
+		m.radarRange = r
+	}
+	if o.north != "" {
+		north, err := parseAngle(o.north, -360, 360)
+		if err != nil {
+			return fmt.Errorf("north: %v", err)
+		}
+		m.northOffset = north * math.Pi / 180
+	}
+	if o.sectors != "" {
+		if m.viewingSectors, err = parseViewingSectors(o.sectors); err != nil {
+			return err
+		}
+	}
+	if o.acoustic != "" {
+		if m.acousticMode, err = strconv.ParseBool(o.acoustic); err != nil {
+			return fmt.Errorf("invalid acoustic mode %q", o.acoustic)
+		}
+	}
+	if o.colors != "" && o.colors != "auto" {
+		p, err := parseColorProfile(o.colors)
+		if err != nil {
+			return err
+		}
+		m.setColorProfile(p)
+	}
+	if o.theme != "" && !m.setTheme(o.theme) {
+		return fmt.Errorf("unknown theme %q, expected one of %s", o.theme, strings.Join(themeNames(), ", "))
+	}
+	if o.renderer != "" {
+		if m.radarRenderer, err = parseRadarRenderer(o.renderer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newSessionModel applies any guest restrictions and starts a session at the client's GeoIP
+// location, then restores the profile saved for its public key
+func newSessionModel(s ssh.Session) *model {
+	m := newModel()
+	m.canExport = settings().Server.SessionExport
+	m.exportTag = s.Context().SessionID()[:8]
+	if a := settings().Auth; a.isGuest(s) {
+		a.restrict(m)
+		m.statusMessage = "Connected as a guest"
+	}
+	// Guests with a fixed location stay at the default
+	if lat, lon, place, ok := geoip.Locate(s.RemoteAddr()); ok && !m.locationLocked {
+		m.lat = lat
+		m.lon = lon
+		m.statusMessage = "Location from your IP: " + place
+		log.Printf("Located session at %s", place)
+	}
+	if key := s.PublicKey(); key != nil {
+		m.profileKey = gossh.FingerprintSHA256(key)
+		if saved, ok := profiles.Load(m.profileKey); ok {
+			m.applyProfile(saved)
+			m.statusMessage = "Profile restored"
+		}
+	}
+	return m
+}
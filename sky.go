@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+const feetPerNM = 6076.12
+
+var compassPoints = []string{"N", "NE", "E", "SE", "S", "SW", "W", "NW"}
+
+// altitudeFeet decodes adsb.lol altitudes, which are either a number of feet or "ground"
+type altitudeFeet struct {
+	Feet   float64
+	Valid  bool
+	Ground bool
+}
+
+func (a *altitudeFeet) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*a = altitudeFeet{}
+		return nil
+	}
+	if bytes.Equal(data, []byte(`"ground"`)) {
+		*a = altitudeFeet{Valid: true, Ground: true}
+		return nil
+	}
+	// Anything else unexpected is treated as unknown rather than failing the whole response
+	var feet float64
+	if err := json.Unmarshal(data, &feet); err != nil {
+		*a = altitudeFeet{}
+		return nil
+	}
+	*a = altitudeFeet{Feet: feet, Valid: true}
+	return nil
+}
+
+// Altitude returns the best known altitude of p in feet, preferring the geometric altitude
+func (p plane) Altitude() (float64, bool) {
+	if p.AltGeom.Valid {
+		return p.AltGeom.Feet, true
+	}
+	if p.AltBaro.Valid {
+		return p.AltBaro.Feet, true
+	}
+	return 0, false
+}
+
+// setSkyPosition sets the elevation angle and slant range of p from an observer at
+// observerElevation feet, accounting for the curvature of the earth. Requires the
+// distance from the observer to already be set.
+func setSkyPosition(p *plane, observerElevation float64) {
+	altitude, ok := p.Altitude()
+	p.HasAltitude = ok
+	if !ok {
+		p.ElevationAngle = 0
+		p.SlantRange = p.DistanceFromObserver
+		return
+	}
+
+	theta := p.DistanceFromObserver / earthRadiusNM
+	r1 := earthRadiusNM + observerElevation/feetPerNM
+	r2 := earthRadiusNM + altitude/feetPerNM
+
+	slant := math.Sqrt(r1*r1 + r2*r2 - 2*r1*r2*math.Cos(theta))
+	p.SlantRange = slant
+	if slant == 0 {
+		p.ElevationAngle = 90
+		return
+	}
+	p.ElevationAngle = math.Asin(math.Max(-1, math.Min(1, (r2*math.Cos(theta)-r1)/slant))) * 180 / math.Pi
+}
+
+// compassPoint returns the nearest of the eight compass points to bearing (radians)
+func compassPoint(bearing float64) string {
+	deg := math.Mod(bearing*180/math.Pi+360, 360)
+	return compassPoints[int(math.Round(deg/45))%len(compassPoints)]
+}
+
+func formatElevation(p plane) string {
+	if !p.HasAltitude {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f°", p.ElevationAngle)
+}
+
+func formatSlantRange(p plane) string {
+	if !p.HasAltitude {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", p.SlantRange)
+}
+
+// selectedPlane returns the plane for the highlighted table row
+func (m *model) selectedPlane() (plane, bool) {
+	row := m.tbl.SelectedRow()
+	if row == nil {
+		return plane{}, false
+	}
+	for _, p := range m.planes {
+		if p.FlightCode == row[0] {
+			return p, true
+		}
+	}
+	return plane{}, false
+}
+
+// lookHint tells the observer where in the sky to find the selected plane
+func (m *model) lookHint() string {
+	p, ok := m.selectedPlane()
+	if !ok {
+		return ""
+	}
+	name := strings.TrimSpace(p.FlightCode)
+	if !p.HasAltitude {
+		return fmt.Sprintf("%s: look towards %s", name, compassPoint(p.BearingFromObserver))
+	}
+	if p.ElevationAngle < 0 {
+		return fmt.Sprintf("%s: below the horizon, towards %s", name, compassPoint(p.BearingFromObserver))
+	}
+	return fmt.Sprintf("%s: look %.0f° up, towards %s", name, p.ElevationAngle, compassPoint(p.BearingFromObserver))
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	statsTopN       = 5
+	statsBarWidth   = 20
+	statsHistHeight = 6
+	// statsWindow is how far back the stats go. Older sightings are dropped, as are locations
+	// nobody has watched for that long.
+	statsWindow = 24 * time.Hour
+	// statsMaxLocations caps how many observer locations are tracked, dropping the least
+	// recently watched first
+	statsMaxLocations = 1000
+)
+
+var histogramBlocks = []rune{' ', '▁', '▂', '▃', '▄', '▅', '▆', '▇', '█'}
+
+type statsSighting struct {
+	hex       string
+	callsign  string
+	airline   string
+	origin    string
+	dest      string
+	firstSeen time.Time
+	lastSeen  time.Time
+	closestNM float64
+	closestAt time.Time
+}
+
+// trafficStats accumulates the sightings at one observer location over the stats window
+type trafficStats struct {
+	mu     sync.Mutex
+	recent map[string]*statsSighting
+	all    []*statsSighting
+	// usedAt is when the location was last recorded or viewed, guarded by statsMu
+	usedAt time.Time
+}
+
+type statsCount struct {
+	label string
+	count int
+}
+
+type statsSummary struct {
+	total        int
+	airlines     []statsCount
+	routes       []statsCount
+	hours        [24]int
+	closestToday *statsSighting
+}
+
+var (
+	statsMu         sync.Mutex
+	statsByLocation = make(map[string]*trafficStats)
+)
+
+// statsFor returns the shared stats for an observer location, creating them if needed
+func statsFor(lat float64, lon float64) *trafficStats {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	now := time.Now()
+	key := fmt.Sprintf("%.4f/%.4f", lat, lon)
+	s, ok := statsByLocation[key]
+	if !ok {
+		evictStats(now)
+		s = &trafficStats{recent: make(map[string]*statsSighting)}
+		statsByLocation[key] = s
+	}
+	s.usedAt = now
+	return s
+}
+
+// evictStats drops locations nobody has watched within the stats window, and then the least
+// recently watched until there's room for another. statsMu must be held.
+func evictStats(now time.Time) {
+	var oldestKey string
+	var oldest time.Time
+	for key, s := range statsByLocation {
+		if now.Sub(s.usedAt) > statsWindow {
+			delete(statsByLocation, key)
+			continue
+		}
+		if oldestKey == "" || s.usedAt.Before(oldest) {
+			oldestKey, oldest = key, s.usedAt
+		}
+	}
+	if len(statsByLocation) >= statsMaxLocations {
+		delete(statsByLocation, oldestKey)
+	}
+}
+
+// Record counts every plane in range, starting a new sighting for planes that weren't
+// seen within sightingGap
+func (s *trafficStats) Record(planes []plane, radarRange int, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range planes {
+		if p.DistanceFromObserver > float64(radarRange) {
+			continue
+		}
+		sighting, ok := s.recent[p.Hex]
+		if !ok || now.Sub(sighting.lastSeen) > sightingGap {
+			sighting = &statsSighting{
+				hex:       p.Hex,
+				firstSeen: now,
+				closestNM: p.DistanceFromObserver,
+				closestAt: now,
+			}
+			s.recent[p.Hex] = sighting
+			s.all = append(s.all, sighting)
+		}
+
+		sighting.lastSeen = now
+		if p.DistanceFromObserver < sighting.closestNM {
+			sighting.closestNM = p.DistanceFromObserver
+			sighting.closestAt = now
+		}
+		if callsign := strings.TrimSpace(p.FlightCode); callsign != "" {
+			sighting.callsign = callsign
+		}
+		if p.RouteInfo.Airline != "" {
+			sighting.airline = p.RouteInfo.Airline
+		}
+		if p.RouteInfo.OriginMunicipality != "" {
+			sighting.origin = p.RouteInfo.OriginMunicipality
+		}
+		if p.RouteInfo.DestMunicipality != "" {
+			sighting.dest = p.RouteInfo.DestMunicipality
+		}
+	}
+
+	for hex, sighting := range s.recent {
+		if now.Sub(sighting.lastSeen) > sightingGap {
+			delete(s.recent, hex)
+		}
+	}
+	s.all = slices.DeleteFunc(s.all, func(sighting *statsSighting) bool {
+		return now.Sub(sighting.lastSeen) > statsWindow
+	})
+}
+
+func topCounts(counts map[string]int, n int) []statsCount {
+	var out []statsCount
+	for label, count := range counts {
+		out = append(out, statsCount{label, count})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].count != out[j].count {
+			return out[i].count > out[j].count
+		}
+		return out[i].label < out[j].label
+	})
+	if len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+func (s *trafficStats) Summary(now time.Time) statsSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	airlines := make(map[string]int)
+	routes := make(map[string]int)
+	var summary statsSummary
+
+	year, month, day := now.Date()
+	for _, sighting := range s.all {
+		if now.Sub(sighting.lastSeen) > statsWindow {
+			continue
+		}
+		summary.total++
+		if sighting.airline != "" {
+			airlines[sighting.airline]++
+		}
+		if sighting.origin != "" && sighting.dest != "" {
+			routes[sighting.origin+" → "+sighting.dest]++
+		}
+		summary.hours[sighting.firstSeen.Hour()]++
+
+		y, mo, d := sighting.lastSeen.Date()
+		if y == year && mo == month && d == day {
+			if summary.closestToday == nil || sighting.closestNM < summary.closestToday.closestNM {
+				c := *sighting
+				summary.closestToday = &c
+			}
+		}
+	}
+
+	summary.airlines = topCounts(airlines, statsTopN)
+	summary.routes = topCounts(routes, statsTopN)
+	return summary
+}
+
+func renderBarChart(r *lipgloss.Renderer, title string, counts []statsCount, colour lipgloss.Color) string {
+	lines := []string{r.NewStyle().Bold(true).Render(title)}
+	if len(counts) == 0 {
+		return strings.Join(append(lines, r.NewStyle().Faint(true).Render("  nothing yet")), "\n")
+	}
+
+	labelWidth := 0
+	for _, c := range counts {
+		labelWidth = max(labelWidth, len([]rune(c.label)))
+	}
+	labelWidth = min(labelWidth, 28)
+
+	bar := r.NewStyle().Foreground(colour)
+	for _, c := range counts {
+		label := []rune(c.label)
+		if len(label) > labelWidth {
+			label = append(label[:labelWidth-1], '…')
+		}
+		width := c.count * statsBarWidth / counts[0].count
+		lines = append(lines, fmt.Sprintf("  %-*s %s %d",
+			labelWidth, string(label), bar.Render(strings.Repeat("█", max(width, 1))), c.count))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func renderHourHistogram(r *lipgloss.Renderer, hours [24]int, colour lipgloss.Color) string {
+	peak := 0
+	for _, h := range hours {
+		peak = max(peak, h)
+	}
+
+	lines := []string{r.NewStyle().Bold(true).Render("Aircraft per hour")}
+	bar := r.NewStyle().Foreground(colour)
+	for row := statsHistHeight - 1; row >= 0; row-- {
+		var b strings.Builder
+		b.WriteString("  ")
+		for _, h := range hours {
+			level := 0
+			if peak > 0 {
+				// Eighths of a row filled at this height
+				level = h*statsHistHeight*8/peak - row*8
+			}
+			level = min(max(level, 0), 8)
+			b.WriteString(strings.Repeat(string(histogramBlocks[level]), 2))
+		}
+		lines = append(lines, bar.Render(b.String()))
+	}
+
+	var axis strings.Builder
+	axis.WriteString("  ")
+	for h := 0; h < 24; h += 6 {
+		axis.WriteString(fmt.Sprintf("%-12s", fmt.Sprintf("%02d", h)))
+	}
+	lines = append(lines, r.NewStyle().Faint(true).Render(axis.String()))
+	return strings.Join(lines, "\n")
+}
+
+func (m *model) renderStats(width, height int) string {
+	summary := statsFor(m.lat, m.lon).Summary(time.Now())
+
+	closest := "Closest overflight today: none yet"
+	if c := summary.closestToday; c != nil {
+		name := c.callsign
+		if name == "" {
+			name = c.hex
+		}
+		closest = fmt.Sprintf("Closest overflight today: %s %.2f NM at %s", name, c.closestNM, c.closestAt.Format("15:04"))
+		if c.airline != "" {
+			closest += " (" + c.airline + ")"
+		}
+	}
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		m.renderer.NewStyle().Bold(true).Foreground(lipgloss.Color(m.theme.Bright)).Render(fmt.Sprintf("Traffic in the last 24 hours: %d aircraft", summary.total)),
+		"",
+		closest,
+		"",
+		renderBarChart(m.renderer, "Top airlines", summary.airlines, lipgloss.Color(m.theme.Medium)),
+		"",
+		renderBarChart(m.renderer, "Top routes", summary.routes, lipgloss.Color(m.theme.Medium)),
+		"",
+		renderHourHistogram(m.renderer, summary.hours, lipgloss.Color(m.theme.Medium)),
+	)
+
+	return m.renderer.NewStyle().
+		Width(width).
+		Height(height).
+		Align(lipgloss.Center, lipgloss.Center).
+		Render(content)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func statsPlane(hex string, distance float64, airline, origin, dest string) plane {
+	return plane{
+		Hex:                  hex,
+		FlightCode:           hex + "  ",
+		DistanceFromObserver: distance,
+		RouteInfo:            FlightRoute{Airline: airline, OriginMunicipality: origin, DestMunicipality: dest},
+	}
+}
+
+// statsPoll is the planes in a poll some time after the test starts
+type statsPoll struct {
+	at     time.Duration
+	planes []plane
+}
+
+func TestTrafficStatsRecord(t *testing.T) {
+	start := time.Date(2025, 7, 1, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		polls       []statsPoll
+		at          time.Duration
+		total       int
+		hours       map[int]int
+		closest     string
+		closestNM   float64
+		closestAt   time.Duration
+		closestNone bool
+	}{
+		{
+			name:        "nothing seen",
+			closestNone: true,
+		},
+		{
+			name: "one plane over several polls",
+			polls: []statsPoll{
+				{0, []plane{statsPlane("abc123", 8, "", "", "")}},
+				{time.Minute, []plane{statsPlane("abc123", 3, "", "", "")}},
+				{2 * time.Minute, []plane{statsPlane("abc123", 5, "", "", "")}},
+			},
+			at:        2 * time.Minute,
+			total:     1,
+			hours:     map[int]int{9: 1},
+			closest:   "abc123",
+			closestNM: 3,
+			closestAt: time.Minute,
+		},
+		{
+			name: "seen again after the sighting gap",
+			polls: []statsPoll{
+				{0, []plane{statsPlane("abc123", 8, "", "", "")}},
+				{time.Hour, []plane{statsPlane("abc123", 9, "", "", "")}},
+			},
+			at:        time.Hour,
+			total:     2,
+			hours:     map[int]int{9: 1, 10: 1},
+			closest:   "abc123",
+			closestNM: 8,
+		},
+		{
+			name: "out of range",
+			polls: []statsPoll{
+				{0, []plane{statsPlane("abc123", 20, "", "", "")}},
+			},
+			closestNone: true,
+		},
+		{
+			name: "dropped after the stats window",
+			polls: []statsPoll{
+				{0, []plane{statsPlane("abc123", 2, "", "", "")}},
+				{25 * time.Hour, []plane{statsPlane("def456", 7, "", "", "")}},
+			},
+			at:        25 * time.Hour,
+			total:     1,
+			hours:     map[int]int{10: 1},
+			closest:   "def456",
+			closestNM: 7,
+			closestAt: 25 * time.Hour,
+		},
+		{
+			name: "closest today only counts today",
+			polls: []statsPoll{
+				{14 * time.Hour, []plane{statsPlane("abc123", 1, "", "", "")}},
+				{16 * time.Hour, []plane{statsPlane("def456", 6, "", "", "")}},
+			},
+			at:        16 * time.Hour,
+			total:     2,
+			hours:     map[int]int{23: 1, 1: 1},
+			closest:   "def456",
+			closestNM: 6,
+			closestAt: 16 * time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		s := &trafficStats{recent: make(map[string]*statsSighting)}
+		for _, poll := range tt.polls {
+			s.Record(poll.planes, 15, start.Add(poll.at))
+		}
+		summary := s.Summary(start.Add(tt.at))
+
+		if summary.total != tt.total {
+			t.Errorf("%s: %d sightings, want %d", tt.name, summary.total, tt.total)
+		}
+		for hour, count := range summary.hours {
+			if count != tt.hours[hour] {
+				t.Errorf("%s: %d sightings at %02d:00, want %d", tt.name, count, hour, tt.hours[hour])
+			}
+		}
+		if tt.closestNone {
+			if summary.closestToday != nil {
+				t.Errorf("%s: closest today %s, want none", tt.name, summary.closestToday.hex)
+			}
+			continue
+		}
+		c := summary.closestToday
+		if c == nil {
+			t.Errorf("%s: no closest today, want %s", tt.name, tt.closest)
+			continue
+		}
+		if c.hex != tt.closest || c.closestNM != tt.closestNM || !c.closestAt.Equal(start.Add(tt.closestAt)) {
+			t.Errorf("%s: closest today %s at %.1f NM at %s, want %s at %.1f NM at %s", tt.name,
+				c.hex, c.closestNM, c.closestAt.Format(time.Kitchen), tt.closest, tt.closestNM, start.Add(tt.closestAt).Format(time.Kitchen))
+		}
+	}
+}
+
+func TestTrafficStatsSummaryCounts(t *testing.T) {
+	now := time.Date(2025, 7, 1, 9, 0, 0, 0, time.UTC)
+	s := &trafficStats{recent: make(map[string]*statsSighting)}
+	s.Record([]plane{
+		statsPlane("a", 5, "British Airways", "London", "Edinburgh"),
+		statsPlane("b", 5, "British Airways", "London", "Edinburgh"),
+		statsPlane("c", 5, "easyJet", "Bristol", "Belfast"),
+		// The route is only counted once both ends are known
+		statsPlane("d", 5, "Ryanair", "Dublin", ""),
+		statsPlane("e", 5, "", "", ""),
+	}, 15, now)
+	summary := s.Summary(now)
+
+	wantAirlines := []statsCount{{"British Airways", 2}, {"Ryanair", 1}, {"easyJet", 1}}
+	if !slices.Equal(summary.airlines, wantAirlines) {
+		t.Errorf("airlines %v, want %v", summary.airlines, wantAirlines)
+	}
+	wantRoutes := []statsCount{{"London → Edinburgh", 2}, {"Bristol → Belfast", 1}}
+	if !slices.Equal(summary.routes, wantRoutes) {
+		t.Errorf("routes %v, want %v", summary.routes, wantRoutes)
+	}
+}
+
+func TestTopCounts(t *testing.T) {
+	tests := []struct {
+		counts map[string]int
+		n      int
+		want   []statsCount
+	}{
+		{counts: nil, n: 5, want: nil},
+		{counts: map[string]int{"a": 1, "b": 3, "c": 2}, n: 5, want: []statsCount{{"b", 3}, {"c", 2}, {"a", 1}}},
+		{counts: map[string]int{"a": 1, "b": 3, "c": 2}, n: 2, want: []statsCount{{"b", 3}, {"c", 2}}},
+		// Ties are broken alphabetically so the order doesn't jump between frames
+		{counts: map[string]int{"z": 2, "y": 2, "x": 2}, n: 2, want: []statsCount{{"x", 2}, {"y", 2}}},
+	}
+	for _, tt := range tests {
+		if got := topCounts(tt.counts, tt.n); !slices.Equal(got, tt.want) {
+			t.Errorf("topCounts(%v, %d) = %v, want %v", tt.counts, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestEvictStats(t *testing.T) {
+	now := time.Now()
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	prev := statsByLocation
+	defer func() { statsByLocation = prev }()
+
+	statsByLocation = map[string]*trafficStats{
+		"stale":  {usedAt: now.Add(-25 * time.Hour)},
+		"recent": {usedAt: now.Add(-time.Hour)},
+	}
+	evictStats(now)
+	if _, ok := statsByLocation["stale"]; ok {
+		t.Errorf("evictStats kept a location unwatched for 25h")
+	}
+	if _, ok := statsByLocation["recent"]; !ok {
+		t.Errorf("evictStats dropped a location watched an hour ago")
+	}
+
+	// When full, the least recently watched makes room
+	statsByLocation = make(map[string]*trafficStats)
+	for i := range statsMaxLocations {
+		statsByLocation[strconv.Itoa(i)] = &trafficStats{usedAt: now.Add(-time.Duration(i) * time.Second)}
+	}
+	oldest := strconv.Itoa(statsMaxLocations - 1)
+	evictStats(now)
+	if len(statsByLocation) != statsMaxLocations-1 {
+		t.Errorf("evictStats left %d locations, want %d", len(statsByLocation), statsMaxLocations-1)
+	}
+	if _, ok := statsByLocation[oldest]; ok {
+		t.Errorf("evictStats kept the least recently watched location")
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// streamAircraft is the JSON representation of a plane sent to stream subscribers
+type streamAircraft struct {
+	Hex         string  `json:"hex"`
+	Flight      string  `json:"flight"`
+	Lat         float64 `json:"lat"`
+	Lon         float64 `json:"lon"`
+	Heading     float64 `json:"heading"`
+	DistanceNM  float64 `json:"distance_nm"`
+	BearingDeg  float64 `json:"bearing_deg"`
+	Airline     string  `json:"airline"`
+	Origin      string  `json:"origin"`
+	Destination string  `json:"destination"`
+}
+
+type streamObserver struct {
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+	RangeNM int     `json:"range_nm"`
+}
+
+// streamMessage is pushed to subscribers after every poll. The first message after
+// subscribing is a "snapshot" with every aircraft in range listed as added. If the poll
+// failed, Error says why and the aircraft are those from the last good poll.
+type streamMessage struct {
+	Type     string           `json:"type"`
+	Time     time.Time        `json:"time"`
+	Observer streamObserver   `json:"observer"`
+	Added    []streamAircraft `json:"added"`
+	Moved    []streamAircraft `json:"moved"`
+	Removed  []string         `json:"removed"`
+	Error    string           `json:"error,omitempty"`
+}
+
+// streamError is sent when the client sends a subscription that can't be used
+type streamError struct {
+	Type  string    `json:"type"`
+	Time  time.Time `json:"time"`
+	Error string    `json:"error"`
+}
+
+// streamSubscription can be sent by the client at any time to change location or range
+type streamSubscription struct {
+	Lat   float64 `json:"lat"`
+	Lon   float64 `json:"lon"`
+	Range int     `json:"range"`
+}
+
+// subscriptionChange is a subscription sent by the client, or why it was rejected
+type subscriptionChange struct {
+	sub streamSubscription
+	err error
+}
+
+var streamUpgrader = websocket.Upgrader{CheckOrigin: streamOriginAllowed}
+
+// streamOriginAllowed lets in clients that aren't browsers, which don't send an Origin, and
+// pages served from the stream's own host or one of the configured origins. Other web
+// pages could otherwise have their visitors subscribe on their behalf.
+func streamOriginAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range strings.Split(settings().Server.StreamOrigins, ",") {
+		allowed = strings.TrimSuffix(strings.TrimSpace(allowed), "/")
+		if allowed == "*" || allowed != "" && strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+func (s streamSubscription) validate() error {
+	if s.Lat < -90 || s.Lat > 90 {
+		return fmt.Errorf("lat must be between -90 and 90")
+	}
+	if s.Lon < -180 || s.Lon > 180 {
+		return fmt.Errorf("lon must be between -180 and 180")
+	}
+	if maxRange := settings().Defaults.MaxRange; s.Range < MIN_RADAR_RANGE || s.Range > maxRange {
+		return fmt.Errorf("range must be between %d and %d", MIN_RADAR_RANGE, maxRange)
+	}
+	return nil
+}
+
+func parseStreamSubscription(q url.Values) (streamSubscription, error) {
+	defaults := settings().Defaults
+	sub := streamSubscription{Lat: defaults.Lat, Lon: defaults.Lon, Range: defaults.Range}
+
+	var err error
+	if v := q.Get("lat"); v != "" {
+		if sub.Lat, err = strconv.ParseFloat(v, 64); err != nil {
+			return sub, fmt.Errorf("invalid lat %q", v)
+		}
+	}
+	if v := q.Get("lon"); v != "" {
+		if sub.Lon, err = strconv.ParseFloat(v, 64); err != nil {
+			return sub, fmt.Errorf("invalid lon %q", v)
+		}
+	}
+	if v := q.Get("range"); v != "" {
+		if sub.Range, err = strconv.Atoi(v); err != nil {
+			return sub, fmt.Errorf("invalid range %q", v)
+		}
+	}
+	return sub, sub.validate()
+}
+
+func newStreamAircraft(p plane) streamAircraft {
+	return streamAircraft{
+		Hex:         p.Hex,
+		Flight:      p.FlightCode,
+		Lat:         p.Lat,
+		Lon:         p.Lon,
+		Heading:     p.Heading,
+		DistanceNM:  math.Round(p.DistanceFromObserver*100) / 100,
+		BearingDeg:  math.Round(p.BearingFromObserver*180/math.Pi*10) / 10,
+		Airline:     p.RouteInfo.Airline,
+		Origin:      p.RouteInfo.OriginMunicipality,
+		Destination: p.RouteInfo.DestMunicipality,
+	}
+}
+
+// pollStream fetches the shared poll for sub and returns the aircraft in range keyed by hex,
+// and the error if the poll failed
+func pollStream(sub streamSubscription) (map[string]streamAircraft, error) {
+	current := make(map[string]streamAircraft)
+	planes, err := sharedFeed.Planes(sub.Lat, sub.Lon, float64(sub.Range))
+	for _, p := range planes {
+		setPlaneLocationDetails(sub.Lat, sub.Lon, &p)
+		if p.DistanceFromObserver <= float64(sub.Range) {
+			current[p.Hex] = newStreamAircraft(p)
+		}
+	}
+	return current, err
+}
+
+// diffStream builds the message describing how current differs from prev
+func diffStream(sub streamSubscription, prev, current map[string]streamAircraft, pollErr error) streamMessage {
+	msg := streamMessage{
+		Type:     "update",
+		Time:     time.Now().UTC(),
+		Observer: streamObserver{Lat: sub.Lat, Lon: sub.Lon, RangeNM: sub.Range},
+		Added:    []streamAircraft{},
+		Moved:    []streamAircraft{},
+		Removed:  []string{},
+	}
+	for hex, a := range current {
+		old, ok := prev[hex]
+		if !ok {
+			msg.Added = append(msg.Added, a)
+		} else if old != a {
+			msg.Moved = append(msg.Moved, a)
+		}
+	}
+	for hex := range prev {
+		if _, ok := current[hex]; !ok {
+			msg.Removed = append(msg.Removed, hex)
+		}
+	}
+	if pollErr != nil {
+		msg.Error = "could not fetch aircraft: " + pollErr.Error()
+	}
+	return msg
+}
+
+// readSubscriptions forwards subscription changes from the client until the connection
+// closes. Fields a message leaves out keep their current values, so {"range": 25} only
+// changes the range.
+func readSubscriptions(conn *websocket.Conn, sub streamSubscription, changes chan<- subscriptionChange, done <-chan struct{}) {
+	defer close(changes)
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if _, ok := err.(*websocket.CloseError); !ok {
+				log.Printf("Stream read error: %v", err)
+			}
+			return
+		}
+		next := sub
+		var change subscriptionChange
+		if err := json.Unmarshal(data, &next); err != nil {
+			change.err = fmt.Errorf("invalid subscription: %v", err)
+		} else if err := next.validate(); err != nil {
+			change.err = fmt.Errorf("invalid subscription: %v", err)
+		} else {
+			sub = next
+			change.sub = sub
+		}
+		select {
+		case changes <- change:
+		case <-done:
+			return
+		}
+	}
+}
+
+func streamHandler(w http.ResponseWriter, r *http.Request) {
+	sub, err := parseStreamSubscription(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if msg, ok := limits.acquireStream(settings().Limits, ip); !ok {
+		log.Printf("Refused stream subscriber from %s: %s", ip, msg)
+		http.Error(w, msg, http.StatusServiceUnavailable)
+		return
+	}
+	defer limits.releaseStream(ip)
+
+	conn, err := streamUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Stream upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+	log.Printf("Stream subscriber connected from %s", r.RemoteAddr)
+
+	changes := make(chan subscriptionChange)
+	done := make(chan struct{})
+	defer close(done)
+	go readSubscriptions(conn, sub, changes, done)
+
+	send := func(msg any) bool {
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if err := conn.WriteJSON(msg); err != nil {
+			log.Printf("Stream write error: %v", err)
+			return false
+		}
+		return true
+	}
+
+	prev, err := pollStream(sub)
+	snapshot := diffStream(sub, nil, prev, err)
+	snapshot.Type = "snapshot"
+	if !send(snapshot) {
+		return
+	}
+
+	// The ticker starts once the first poll is back so each tick finds it due again
+	ticker := time.NewTicker(settings().Sources.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case change, ok := <-changes:
+			if !ok {
+				log.Printf("Stream subscriber %s disconnected", r.RemoteAddr)
+				return
+			}
+			if change.err != nil {
+				if !send(streamError{Type: "error", Time: time.Now().UTC(), Error: change.err.Error()}) {
+					return
+				}
+				continue
+			}
+			sub = change.sub
+			prev, err = pollStream(sub)
+			snapshot := diffStream(sub, nil, prev, err)
+			snapshot.Type = "snapshot"
+			if !send(snapshot) {
+				return
+			}
+			ticker.Reset(settings().Sources.PollInterval)
+		case <-ticker.C:
+			current, err := pollStream(sub)
+			if !send(diffStream(sub, prev, current, err)) {
+				return
+			}
+			prev = current
+		}
+	}
+}
+
+func newStreamServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", streamHandler)
+	return &http.Server{Addr: addr, Handler: mux}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+)
+
+func TestDiffStream(t *testing.T) {
+	a := streamAircraft{Hex: "aaaaaa", Flight: "BAW1", Lat: 53.8, Lon: -1.6}
+	b := streamAircraft{Hex: "bbbbbb", Flight: "EZY2", Lat: 53.9, Lon: -1.7}
+	bMoved := b
+	bMoved.Lat = 54
+	c := streamAircraft{Hex: "cccccc", Flight: "RYR3", Lat: 53.7, Lon: -1.5}
+
+	tests := []struct {
+		name          string
+		prev, current []streamAircraft
+		pollErr       error
+		added, moved  []string
+		removed       []string
+		err           string
+	}{
+		{name: "nothing either time"},
+		{name: "snapshot", current: []streamAircraft{a, b}, added: []string{"aaaaaa", "bbbbbb"}},
+		{name: "unchanged", prev: []streamAircraft{a, b}, current: []streamAircraft{a, b}},
+		{
+			name:    "added, moved and removed",
+			prev:    []streamAircraft{a, b},
+			current: []streamAircraft{bMoved, c},
+			added:   []string{"cccccc"},
+			moved:   []string{"bbbbbb"},
+			removed: []string{"aaaaaa"},
+		},
+		{
+			name:    "failed poll keeps the last aircraft",
+			prev:    []streamAircraft{a},
+			current: []streamAircraft{a},
+			pollErr: errors.New("timeout"),
+			err:     "could not fetch aircraft: timeout",
+		},
+	}
+	for _, tt := range tests {
+		sub := streamSubscription{Lat: 53.8, Lon: -1.6, Range: 15}
+		msg := diffStream(sub, aircraftByHex(tt.prev), aircraftByHex(tt.current), tt.pollErr)
+		if msg.Type != "update" {
+			t.Errorf("%s: type %q, want update", tt.name, msg.Type)
+		}
+		if msg.Observer != (streamObserver{Lat: 53.8, Lon: -1.6, RangeNM: 15}) {
+			t.Errorf("%s: observer %+v", tt.name, msg.Observer)
+		}
+		// Empty lists are sent as [] rather than null
+		if msg.Added == nil || msg.Moved == nil || msg.Removed == nil {
+			t.Errorf("%s: has a nil list", tt.name)
+		}
+		if got := aircraftHexes(msg.Added); !slices.Equal(got, sorted(tt.added)) {
+			t.Errorf("%s: added %v, want %v", tt.name, got, tt.added)
+		}
+		if got := aircraftHexes(msg.Moved); !slices.Equal(got, sorted(tt.moved)) {
+			t.Errorf("%s: moved %v, want %v", tt.name, got, tt.moved)
+		}
+		if got := sorted(msg.Removed); !slices.Equal(got, sorted(tt.removed)) {
+			t.Errorf("%s: removed %v, want %v", tt.name, got, tt.removed)
+		}
+		if msg.Error != tt.err {
+			t.Errorf("%s: error %q, want %q", tt.name, msg.Error, tt.err)
+		}
+	}
+}
+
+func TestParseStreamSubscription(t *testing.T) {
+	defaults := settings().Defaults
+	tests := []struct {
+		query string
+		want  streamSubscription
+		err   bool
+	}{
+		{query: "", want: streamSubscription{Lat: defaults.Lat, Lon: defaults.Lon, Range: defaults.Range}},
+		{query: "lat=51.47&lon=-0.4543&range=25", want: streamSubscription{Lat: 51.47, Lon: -0.4543, Range: 25}},
+		{query: "range=40", want: streamSubscription{Lat: defaults.Lat, Lon: defaults.Lon, Range: 40}},
+		{query: "lon=", want: streamSubscription{Lat: defaults.Lat, Lon: defaults.Lon, Range: defaults.Range}},
+
+		{query: "lat=north", err: true},
+		{query: "range=2.5", err: true},
+		{query: "lat=91", err: true},
+		{query: "lon=-181", err: true},
+		{query: "range=0", err: true},
+		{query: "range=100000", err: true},
+	}
+	for _, tt := range tests {
+		q, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatalf("url.ParseQuery(%q) failed: %v", tt.query, err)
+		}
+		got, err := parseStreamSubscription(q)
+		if tt.err {
+			if err == nil {
+				t.Errorf("parseStreamSubscription(%q) = %+v, want an error", tt.query, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseStreamSubscription(%q) failed: %v", tt.query, err)
+		} else if got != tt.want {
+			t.Errorf("parseStreamSubscription(%q) = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestStreamOriginAllowed(t *testing.T) {
+	tests := []struct {
+		origins string
+		origin  string
+		want    bool
+	}{
+		// Clients that aren't browsers don't send an Origin
+		{"", "", true},
+		{"", "http://radar.example.com:8080", true},
+		{"", "https://attacker.example", false},
+		{"https://maps.example.com", "https://maps.example.com", true},
+		{"https://maps.example.com/, https://other.example", "https://MAPS.example.com", true},
+		{"https://maps.example.com", "http://maps.example.com", false},
+		{"*", "https://anywhere.example", true},
+		{"", "not a url", false},
+	}
+	for _, tt := range tests {
+		c := *settings()
+		c.Server.StreamOrigins = tt.origins
+		withConfig(t, &c)
+
+		r := httptest.NewRequest("GET", "http://radar.example.com:8080/stream", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := streamOriginAllowed(r); got != tt.want {
+			t.Errorf("streamOriginAllowed(%q) with origins %q = %v, want %v", tt.origin, tt.origins, got, tt.want)
+		}
+	}
+}
+
+func aircraftByHex(aircraft []streamAircraft) map[string]streamAircraft {
+	byHex := make(map[string]streamAircraft)
+	for _, a := range aircraft {
+		byHex[a.Hex] = a
+	}
+	return byHex
+}
+
+func aircraftHexes(aircraft []streamAircraft) []string {
+	var hexes []string
+	for _, a := range aircraft {
+		hexes = append(hexes, a.Hex)
+	}
+	return sorted(hexes)
+}
+
+func sorted(s []string) []string {
+	s = slices.Clone(s)
+	slices.Sort(s)
+	return s
+}
+
+// withConfig makes c the current config until the test ends
+func withConfig(t *testing.T, c *config) {
+	prev := settings()
+	currentConfig.Store(c)
+	t.Cleanup(func() { currentConfig.Store(prev) })
+}
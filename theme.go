@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// theme is the palette the radar, table, stats and status bar are drawn in. Colours are hex
+// ("#00ff00") or ANSI 256 colour numbers ("240").
+type theme struct {
+	// Bright, Medium, Dim and Dimmest are the sweep and plane fade, brightest first
+	Bright       string `toml:"bright" yaml:"bright"`
+	Medium       string `toml:"medium" yaml:"medium"`
+	Dim          string `toml:"dim" yaml:"dim"`
+	Dimmest      string `toml:"dimmest" yaml:"dimmest"`
+	Sector       string `toml:"sector" yaml:"sector"`
+	Frame        string `toml:"frame" yaml:"frame"`
+	Heard        string `toml:"heard" yaml:"heard"`
+	Border       string `toml:"border" yaml:"border"`
+	SelectedText string `toml:"selected_text" yaml:"selected_text"`
+	StatusBar    string `toml:"status_bar" yaml:"status_bar"`
+	Error        string `toml:"error" yaml:"error"`
+}
+
+const defaultTheme = "green"
+
+// builtinThemeNames are the built in themes in the order t cycles through them
+var builtinThemeNames = []string{"green", "amber", "blue", "monochrome", "high-contrast", "colourblind"}
+
+var builtinThemes = map[string]theme{
+	"green": {
+		Bright: "#00ff00", Medium: "#00bc00", Dim: "#007900", Dimmest: "#001b00",
+		Sector: "#002a10", Frame: "#3b3a3a", Heard: "#ffffff",
+		Border: "240", SelectedText: "#000000", StatusBar: "235", Error: "#ff5f5f",
+	},
+	"amber": {
+		Bright: "#ffb000", Medium: "#c78a00", Dim: "#7a5400", Dimmest: "#241900",
+		Sector: "#2e1f00", Frame: "#3b3a3a", Heard: "#ffffff",
+		Border: "240", SelectedText: "#000000", StatusBar: "235", Error: "#ff5f5f",
+	},
+	"blue": {
+		Bright: "#5fd7ff", Medium: "#1e88e5", Dim: "#0d47a1", Dimmest: "#061838",
+		Sector: "#0a2230", Frame: "#3b3a3a", Heard: "#ffffff",
+		Border: "240", SelectedText: "#000000", StatusBar: "235", Error: "#ff5f5f",
+	},
+	"monochrome": {
+		Bright: "#ffffff", Medium: "#b2b2b2", Dim: "#6c6c6c", Dimmest: "#262626",
+		Sector: "#1c1c1c", Frame: "#4e4e4e", Heard: "#ffffff",
+		Border: "244", SelectedText: "#000000", StatusBar: "236", Error: "#ffffff",
+	},
+	"high-contrast": {
+		Bright: "#ffffff", Medium: "#ffff00", Dim: "#00ffff", Dimmest: "#5f5f00",
+		Sector: "#00005f", Frame: "#ffffff", Heard: "#ff00ff",
+		Border: "15", SelectedText: "#000000", StatusBar: "0", Error: "#ff0000",
+	},
+	// Blues against orange from the Okabe-Ito palette, which deuteranopes can tell apart,
+	// rather than shades of green
+	"colourblind": {
+		Bright: "#56b4e9", Medium: "#0072b2", Dim: "#004a75", Dimmest: "#001e30",
+		Sector: "#3d2900", Frame: "#3b3a3a", Heard: "#e69f00",
+		Border: "240", SelectedText: "#ffffff", StatusBar: "235", Error: "#d55e00",
+	},
+}
+
+var hexColour = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+func validColour(s string) bool {
+	if hexColour.MatchString(s) {
+		return true
+	}
+	n, err := strconv.Atoi(s)
+	return err == nil && n >= 0 && n <= 255
+}
+
+// validate checks the colours set in a theme from the config. Unset ones are allowed and
+// taken from the default theme.
+func (t theme) validate(name string) error {
+	v := reflect.ValueOf(t)
+	for i := 0; i < v.NumField(); i++ {
+		if colour := v.Field(i).String(); colour != "" && !validColour(colour) {
+			return fmt.Errorf("theme %s %s %q must be a hex colour or an ANSI colour number", name, v.Type().Field(i).Tag.Get("toml"), colour)
+		}
+	}
+	return nil
+}
+
+// withDefaults fills the colours a theme leaves unset from base
+func (t theme) withDefaults(base theme) theme {
+	v := reflect.ValueOf(&t).Elem()
+	b := reflect.ValueOf(base)
+	for i := 0; i < v.NumField(); i++ {
+		if v.Field(i).String() == "" {
+			v.Field(i).SetString(b.Field(i).String())
+		}
+	}
+	return t
+}
+
+// themeNames lists the built in themes and then the config's own, in cycling order
+func themeNames() []string {
+	names := append([]string(nil), builtinThemeNames...)
+	var custom []string
+	for name := range settings().Themes {
+		if _, ok := builtinThemes[name]; !ok {
+			custom = append(custom, name)
+		}
+	}
+	sort.Strings(custom)
+	return append(names, custom...)
+}
+
+// themeNamed finds a theme, themes in the config replacing built in ones of the same name
+func themeNamed(name string) (theme, bool) {
+	if t, ok := settings().Themes[name]; ok {
+		return t.withDefaults(builtinThemes[defaultTheme]), true
+	}
+	t, ok := builtinThemes[name]
+	return t, ok
+}
+
+func (m *model) setTheme(name string) bool {
+	t, ok := themeNamed(name)
+	if !ok {
+		return false
+	}
+	m.themeName = name
+	m.theme = t
+	if m.colors == termenv.Ascii {
+		m.theme = theme{}
+	}
+	if m.tableLoaded {
+		m.tbl.SetStyles(m.tableStyles())
+	}
+	return true
+}
+
+// cycleTheme switches to the theme after the current one
+func (m *model) cycleTheme() {
+	names := themeNames()
+	next := 0
+	for i, name := range names {
+		if name == m.themeName {
+			next = (i + 1) % len(names)
+		}
+	}
+	m.setTheme(names[next])
+	m.statusMessage = "Theme: " + m.themeName
+}
+
+func (m *model) tableStyles() table.Styles {
+	return table.Styles{
+		Header: m.renderer.NewStyle().
+			Padding(0, 1).
+			BorderStyle(lipgloss.NormalBorder()).
+			BorderForeground(lipgloss.Color(m.theme.Border)).
+			BorderBottom(true),
+		Cell: m.renderer.NewStyle().Padding(0, 1),
+		Selected: m.renderer.NewStyle().
+			Foreground(lipgloss.Color(m.theme.SelectedText)).
+			Background(lipgloss.Color(m.theme.Medium)).
+			Reverse(m.colors == termenv.Ascii),
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// runTUI runs the radar in the invoking terminal, without the SSH server. It takes the same
+// options as an SSH session, e.g. "whatplaneisthat tui --location EGLL --range 25".
+func runTUI(args []string) {
+	opts := &sessionOptions{}
+	fs := opts.flagSet("tui", os.Stderr)
+	configPath := addConfigFlag(fs)
+	logPath := fs.String("log", "", "File to write logs to (default: discard them)")
+	err := opts.parse(fs, args, os.Environ())
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	c, err := useConfigFile(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Logs would draw over the radar
+	log.SetOutput(io.Discard)
+	if *logPath != "" {
+		f, err := tea.LogToFile(*logPath, "")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not open log %s: %v\n", *logPath, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+	}
+
+	if c.Server.History != "" {
+		store, err := openSightingStore(c.Server.History)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not open sightings history %s: %v\n", c.Server.History, err)
+			os.Exit(1)
+		}
+		sightings = store
+		defer sightings.Close()
+	}
+
+	m := newModel()
+	m.setRenderer(lipgloss.DefaultRenderer())
+	// Exports are written where the user running the radar can get at them
+	m.canExport = true
+	m.exportTag = "tui"
+	if err := opts.apply(m); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if _, err := tea.NewProgram(m, tea.WithAltScreen()).Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not run the radar: %v\n", err)
+		os.Exit(1)
+	}
+}